@@ -6,8 +6,12 @@ import (
 	"net/http"
 	"social/docs"
 	"social/internal/auth"
+	"social/internal/events"
 	"social/internal/mailer"
+	"social/internal/objectstore"
 	"social/internal/store"
+	"social/internal/stream"
+	"social/internal/webhooks"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -24,16 +28,75 @@ type application struct {
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
 	authenticator auth.Authenticator
+	hub           stream.Hub
+	events        events.Execer
+	wsConns       wsConnLimiter
+	webhookSender *webhooks.Sender
+	objects       objectstore.Store
 }
 
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiURL      string
-	mail        mailConfig
-	frontendURL string
-	auth        authConfig
+	addr           string
+	db             dbConfig
+	env            string
+	apiURL         string
+	mail           mailConfig
+	frontendURL    string
+	auth           authConfig
+	maxPinnedPosts int
+	scheduler      schedulerConfig
+	trash          trashConfig
+	trending       trendingConfig
+	stream         streamConfig
+	ws             wsConfig
+	webhooks       webhookConfig
+	media          mediaConfig
+	requireIfMatch bool
+}
+
+type mediaConfig struct {
+	dir                 string
+	baseURL             string
+	maxBytes            int64
+	maxPixels           int
+	thumbnailSize       int
+	unattachedRetention time.Duration
+	purgeBatchSize      int
+}
+
+type webhookConfig struct {
+	interval    time.Duration
+	batchSize   int
+	timeout     time.Duration
+	maxAttempts int
+	maxFailures int
+	retention   time.Duration
+}
+
+type wsConfig struct {
+	maxConnsPerUser int
+	pingInterval    time.Duration
+}
+
+type streamConfig struct {
+	history   int
+	buffer    int
+	heartbeat time.Duration
+}
+
+type trendingConfig struct {
+	interval time.Duration
+	size     int
+}
+
+type trashConfig struct {
+	retention     time.Duration
+	purgeInterval time.Duration
+}
+
+type schedulerConfig struct {
+	interval  time.Duration
+	batchSize int
 }
 
 type authConfig struct {
@@ -76,7 +139,7 @@ func (app *application) mount() http.Handler {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(timeoutMiddleware(60 * time.Second))
 
 	r.Route("/v1", func(r chi.Router) {
 		r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
@@ -90,10 +153,64 @@ func (app *application) mount() http.Handler {
 			r.Post("/", app.createPostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin,", app.deletePostHandler))
+				// trashed posts are invisible to postsContextMiddleware
+				r.Post("/restore", app.restorePostHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.postsContextMiddleware)
+					r.Get("/", app.getPostHandler)
+					r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+					r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+					r.Put("/pin", app.checkPostOwnership("admin", app.pinPostHandler))
+					r.Put("/unpin", app.checkPostOwnership("admin", app.unpinPostHandler))
+					r.Get("/poll", app.getPollHandler)
+					r.Post("/poll/votes", app.votePollHandler)
+					r.Put("/repost", app.repostPostHandler)
+					r.Put("/unrepost", app.unrepostPostHandler)
+					r.Put("/reaction", app.reactPostHandler)
+					r.Delete("/reaction", app.unreactPostHandler)
+					r.Get("/reactions", app.getPostReactionsHandler)
+					r.Put("/bookmark", app.bookmarkPostHandler)
+					r.Put("/unbookmark", app.unbookmarkPostHandler)
+
+					r.Route("/revisions", func(r chi.Router) {
+						r.Get("/", app.getPostRevisionsHandler)
+						r.Get("/diff", app.diffPostRevisionsHandler)
+						r.Get("/{version}", app.getPostRevisionHandler)
+						r.Post("/{version}/restore", app.checkPostOwnership("moderator", app.restorePostRevisionHandler))
+					})
+
+					r.Post("/comments", app.createCommentHandler)
+					r.Route("/comments/{commentID}", func(r chi.Router) {
+						r.Post("/restore", app.restoreCommentHandler)
+
+						r.Group(func(r chi.Router) {
+							r.Use(app.commentContextMiddleware)
+							r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+							r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+						})
+					})
+				})
+			})
+		})
+
+		// Media routes
+		r.Route("/media", func(r chi.Router) {
+			// uploaded files, when they are kept on the local filesystem
+			if h, ok := app.objects.(http.Handler); ok {
+				r.Handle("/files/*", http.StripPrefix("/v1/media/files", h))
+			}
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/", app.uploadMediaHandler)
+
+				r.Route("/{mediaID}", func(r chi.Router) {
+					r.Use(app.mediaContextMiddleware)
+					r.Get("/", app.getMediaHandler)
+					r.Patch("/", app.updateMediaHandler)
+					r.Delete("/", app.deleteMediaHandler)
+				})
 			})
 		})
 
@@ -101,12 +218,69 @@ func (app *application) mount() http.Handler {
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
 
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Put("/privacy", app.updatePrivacyHandler)
+				r.Get("/blocks", app.getBlockedUsersHandler)
+				r.Get("/mutes", app.getMutedUsersHandler)
+				r.Get("/drafts", app.getDraftsHandler)
+				r.Get("/trash", app.getTrashHandler)
+
+				r.Route("/bookmarks", func(r chi.Router) {
+					r.Get("/", app.getBookmarksHandler)
+					r.Get("/collections", app.getBookmarkCollectionsHandler)
+					r.Post("/collections", app.createBookmarkCollectionHandler)
+
+					r.Route("/collections/{collectionID}", func(r chi.Router) {
+						r.Use(app.bookmarkCollectionContextMiddleware)
+						r.Patch("/", app.renameBookmarkCollectionHandler)
+						r.Delete("/", app.deleteBookmarkCollectionHandler)
+					})
+				})
+
+				r.Route("/filters", func(r chi.Router) {
+					r.Get("/", app.getFeedFiltersHandler)
+					r.Post("/", app.createFeedFilterHandler)
+
+					r.Route("/{filterID}", func(r chi.Router) {
+						r.Use(app.feedFilterContextMiddleware)
+						r.Patch("/", app.updateFeedFilterHandler)
+						r.Delete("/", app.deleteFeedFilterHandler)
+					})
+				})
+
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Get("/", app.getWebhooksHandler)
+					r.Post("/", app.createWebhookHandler)
+
+					r.Route("/{webhookID}", func(r chi.Router) {
+						r.Use(app.webhookContextMiddleware)
+						r.Get("/", app.getWebhookHandler)
+						r.Patch("/", app.updateWebhookHandler)
+						r.Delete("/", app.deleteWebhookHandler)
+						r.Get("/deliveries", app.getWebhookDeliveriesHandler)
+					})
+				})
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Get("/", app.getFollowRequestsHandler)
+					r.Put("/{followerID}/accept", app.acceptFollowRequestHandler)
+					r.Put("/{followerID}/reject", app.rejectFollowRequestHandler)
+				})
+			})
+
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 
 				r.Get("/", app.getUserHandler)
+				r.Get("/posts", app.getUserPostsHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Put("/unblock", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Put("/unmute", app.unmuteUserHandler)
 
 			})
 
@@ -117,6 +291,60 @@ func (app *application) mount() http.Handler {
 
 		})
 
+		// Stream routes
+		r.Route("/stream", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.streamHandler)
+		})
+
+		// WebSocket gateway, authenticated by the handler as browsers can't
+		// set the Authorization header
+		r.Get("/ws", app.websocketHandler)
+
+		// Conversations routes
+		r.Route("/conversations", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getConversationsHandler)
+			r.Post("/", app.createConversationHandler)
+
+			r.Route("/{conversationID}", func(r chi.Router) {
+				r.Use(app.conversationContextMiddleware)
+				r.Get("/", app.getConversationHandler)
+				r.Get("/messages", app.getMessagesHandler)
+				r.Post("/messages", app.sendMessageHandler)
+				r.Put("/read", app.markConversationReadHandler)
+			})
+		})
+
+		// Notifications routes
+		r.Route("/notifications", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getNotificationsHandler)
+			r.Get("/unread-count", app.getUnreadNotificationsCountHandler)
+			r.Put("/read-all", app.markAllNotificationsReadHandler)
+			r.Put("/{notificationID}/read", app.markNotificationReadHandler)
+		})
+
+		// Explore routes
+		r.Route("/explore", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getPublicTimelineHandler)
+		})
+
+		// Trending routes
+		r.Route("/trending", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/tags", app.getTrendingTagsHandler)
+			r.Get("/posts", app.getTrendingPostsHandler)
+		})
+
+		// Tags routes
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.searchTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
 		//Public routes
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
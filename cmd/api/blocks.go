@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user, removing follows in both directions and preventing following, commenting and viewing
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyUserRelation(w, r, app.store.Blocks.Block)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Removes a block on a user
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyUserRelation(w, r, app.store.Blocks.Unblock)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Hides a user's posts from the authenticated user's feed
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyUserRelation(w, r, app.store.Mutes.Mute)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Shows a previously muted user's posts in the feed again
+//	@Tags			users
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyUserRelation(w, r, app.store.Mutes.Unmute)
+}
+
+// applyUserRelation runs apply between the authenticated user and the user in the URL.
+func (app *application) applyUserRelation(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userID, targetID int64) error) {
+	user := getUserFromContext(r)
+
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if targetID == user.ID {
+		app.badRequestResponse(w, r, errors.New("you can't do this to yourself"))
+		return
+	}
+
+	if err := apply(r.Context(), user.ID, targetID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBlockedUsers godoc
+//
+//	@Summary		Lists blocked users
+//	@Description	Lists the users blocked by the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.Block
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	blocks, err := app.store.Blocks.GetBlocked(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, blocks); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetMutedUsers godoc
+//
+//	@Summary		Lists muted users
+//	@Description	Lists the users muted by the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.Mute
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) getMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	mutes, err := app.store.Mutes.GetMuted(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, mutes); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
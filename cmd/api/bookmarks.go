@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type bookmarkCollectionKey string
+
+const bookmarkCollectionCtx bookmarkCollectionKey = "bookmarkCollection"
+
+type BookmarkPostPayload struct {
+	CollectionID *int64 `json:"collection_id" validate:"omitempty,gt=0"`
+}
+
+type BookmarkCollectionPayload struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post for later, optionally in one of the authenticated user's collections. Bookmarking
+//	@Description	a post again moves it to the given collection, or out of any when collection_id is omitted.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Param			postID	path	int					true	"Post ID"
+//	@Param			payload	body	BookmarkPostPayload	false	"Collection"
+//	@Success		204		"Post bookmarked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	// the body is optional
+	var payload BookmarkPostPayload
+	if err := readJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestResponse(w, r, errors.New("only published posts can be bookmarked"))
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Bookmarks.Add(r.Context(), user.ID, post.ID, payload.CollectionID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnbookmarkPost godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the bookmarks of the authenticated user
+//	@Tags			bookmarks
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Bookmark removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/unbookmark [put]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Bookmarks.Remove(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Lists bookmarks
+//	@Description	Lists the posts bookmarked by the authenticated user, most recently bookmarked first. Posts
+//	@Description	that were deleted or can't be read anymore are left out.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			collection_id	query		int		false	"Only bookmarks in this collection"
+//	@Param			limit			query		int		false	"Number of posts to return"	default(20)
+//	@Param			offset			query		int		false	"Number of posts to skip"	default(0)
+//	@Param			sort			query		string	false	"Sort order: asc or desc"	default(desc)	Enum(asc, desc)
+//	@Success		200				{object}	[]store.PostWithMetaData
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	var collectionID *int64
+	if c := r.URL.Query().Get("collection_id"); c != "" {
+		id, err := strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		// an unknown collection is a 404 rather than an empty page
+		if _, err := app.store.BookmarkCollections.GetByID(ctx, id, user.ID); err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		collectionID = &id
+	}
+
+	posts, err := app.store.Bookmarks.GetPosts(ctx, user.ID, collectionID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetBookmarkCollections godoc
+//
+//	@Summary		Lists bookmark collections
+//	@Description	Lists the bookmark collections of the authenticated user by name
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookmarkCollection
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections [get]
+func (app *application) getBookmarkCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	collections, err := app.store.BookmarkCollections.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, collections); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateBookmarkCollection godoc
+//
+//	@Summary		Creates a bookmark collection
+//	@Description	Creates a private collection to file bookmarks in. Names are unique per user, ignoring case.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		BookmarkCollectionPayload	true	"Collection"
+//	@Success		201		{object}	store.BookmarkCollection
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections [post]
+func (app *application) createBookmarkCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload BookmarkCollectionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	collection := &store.BookmarkCollection{UserID: user.ID, Name: payload.Name}
+
+	if err := app.store.BookmarkCollections.Create(r.Context(), collection); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, collection); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RenameBookmarkCollection godoc
+//
+//	@Summary		Renames a bookmark collection
+//	@Description	Renames a bookmark collection of the authenticated user
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			collectionID	path		int							true	"Collection ID"
+//	@Param			payload			body		BookmarkCollectionPayload	true	"Collection"
+//	@Success		200				{object}	store.BookmarkCollection
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections/{collectionID} [patch]
+func (app *application) renameBookmarkCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection := getBookmarkCollectionFromCtx(r)
+
+	var payload BookmarkCollectionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection.Name = payload.Name
+
+	if err := app.store.BookmarkCollections.Rename(r.Context(), collection); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, collection); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteBookmarkCollection godoc
+//
+//	@Summary		Deletes a bookmark collection
+//	@Description	Deletes a bookmark collection. Its bookmarks are kept, outside any collection.
+//	@Tags			bookmarks
+//	@Param			collectionID	path	int	true	"Collection ID"
+//	@Success		204				"Collection deleted"
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks/collections/{collectionID} [delete]
+func (app *application) deleteBookmarkCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection := getBookmarkCollectionFromCtx(r)
+
+	if err := app.store.BookmarkCollections.Delete(r.Context(), collection.ID, collection.UserID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) bookmarkCollectionContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		collection, err := app.store.BookmarkCollections.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, bookmarkCollectionCtx, collection)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getBookmarkCollectionFromCtx(r *http.Request) *store.BookmarkCollection {
+	collection, _ := r.Context().Value(bookmarkCollectionCtx).(*store.BookmarkCollection)
+	return collection
+}
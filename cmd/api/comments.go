@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// Create comment godoc
+//
+//	@Summary		Create a comment
+//	@Description	Comments on a post or replies to one of its comments, notifying the post author,
+//	@Description	the author of the comment replied to and the users mentioned. The content is Markdown,
+//	@Description	returned rendered to sanitized HTML as content_html
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	var payload CreateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	var parent *store.Comment
+	if payload.ParentID != nil {
+		var err error
+		parent, err = app.store.Comments.GetByID(ctx, *payload.ParentID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		if parent.PostID != post.ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+	}
+
+	comment := &store.Comment{
+		PostID:   post.ID,
+		ParentID: payload.ParentID,
+		UserID:   user.ID,
+		Content:  payload.Content,
+		Mentions: parseMentions(payload.Content),
+		User:     store.User{ID: user.ID, UserName: user.UserName},
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the post author replied to is only told about the reply
+	if parent != nil {
+		app.notify(ctx, parent.UserID, user.ID, store.NotificationReply, &post.ID, &comment.ID)
+	}
+	if parent == nil || parent.UserID != post.UserID {
+		app.notify(ctx, post.UserID, user.ID, store.NotificationComment, &post.ID, &comment.ID)
+	}
+	app.notifyMentions(ctx, user.ID, comment.Mentions, nil, &post.ID, &comment.ID)
+	app.publish(postTopic(post.ID), "comment.created", comment)
+	app.deliverWebhook(ctx, post.UserID, store.WebhookCommentCreated, comment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Update comment godoc
+//
+//	@Summary		Update a comment
+//	@Description	Edits the content of a comment, notifying the users newly mentioned in it
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	var payload UpdateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	previousMentions := comment.Mentions
+	comment.Content = payload.Content
+	comment.Mentions = parseMentions(payload.Content)
+
+	ctx := r.Context()
+
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notifyMentions(ctx, getUserFromContext(r).ID, comment.Mentions, previousMentions, &comment.PostID, &comment.ID)
+	app.publish(postTopic(comment.PostID), "comment.updated", comment)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Delete comment godoc
+//
+//	@Summary		Delete a comment
+//	@Description	Moves a comment to the trash, restorable until the retention window passes
+//	@Tags			comments
+//	@Param			postID		path	int	true	"Post ID"
+//	@Param			commentID	path	int	true	"Comment ID"
+//	@Success		204			"Comment deleted"
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.publish(postTopic(comment.PostID), "comment.deleted", map[string]int64{"id": comment.ID, "post_id": comment.PostID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Restore comment godoc
+//
+//	@Summary		Restores a deleted comment
+//	@Description	Restores a comment from the trash within the retention window
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/restore [post]
+func (app *application) restoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	post := getPostFromCtx(r)
+
+	comment, err := app.store.Comments.GetTrashedByID(ctx, id)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if comment.PostID != post.ID || !app.inRetention(comment.DeletedAt) {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	allowed, err := app.isOwnerOrRole(ctx, getUserFromContext(r), comment.UserID, "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// like restorePostHandler, someone else's trash is reported missing
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Comments.Restore(ctx, comment.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	comment.DeletedAt = nil
+
+	app.publish(postTopic(comment.PostID), "comment.restored", comment)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// commentContextMiddleware loads the comment in the URL, which has to belong
+// to the post loaded by postsContextMiddleware.
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		post := getPostFromCtx(r)
+
+		comment, err := app.store.Comments.GetByID(ctx, id)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if comment.PostID != post.ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
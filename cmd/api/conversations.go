@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type conversationKey string
+
+const conversationCtx conversationKey = "conversation"
+
+type CreateConversationPayload struct {
+	ParticipantIDs []int64 `json:"participant_ids" validate:"required,min=1,dive,gt=0"`
+	Title          *string `json:"title" validate:"omitempty,max=100"`
+}
+
+type SendMessagePayload struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type MarkConversationReadPayload struct {
+	MessageID int64 `json:"message_id" validate:"required,gt=0"`
+}
+
+// MessagesPage is a page of messages, newest first. NextCursor is passed as
+// before to fetch older ones and is null on the last page.
+type MessagesPage struct {
+	Messages   []store.Message `json:"messages"`
+	NextCursor *int64          `json:"next_cursor"`
+}
+
+// GetConversations godoc
+//
+//	@Summary		Lists conversations
+//	@Description	Lists the conversations of the authenticated user, most recently active first, with their
+//	@Description	participants, last message and unread count
+//	@Tags			messages
+//	@Produce		json
+//	@Param			limit	query		int	false	"Number of conversations to return"	default(20)
+//	@Param			offset	query		int	false	"Number of conversations to skip"	default(0)
+//	@Success		200		{object}	[]store.Conversation
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [get]
+func (app *application) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	conversations, err := app.store.Conversations.GetByUserID(r.Context(), user.ID, fq.Limit, fq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, conversations); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateConversation godoc
+//
+//	@Summary		Starts a conversation
+//	@Description	Starts a 1:1 conversation with a single participant, or a group with several. Private accounts
+//	@Description	can only be added by their followers and users with a block either way can't be added at all.
+//	@Description	Starting a 1:1 conversation that already exists returns it with 200.
+//	@Tags			messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateConversationPayload	true	"Conversation"
+//	@Success		201		{object}	store.Conversation
+//	@Success		200		{object}	store.Conversation
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [post]
+func (app *application) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateConversationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	ids := slices.Clone(payload.ParticipantIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if slices.Contains(ids, user.ID) {
+		app.badRequestResponse(w, r, errors.New("participants can't include yourself"))
+		return
+	}
+	if len(ids)+1 > store.MaxConversationParticipants {
+		app.badRequestResponse(w, r, fmt.Errorf("conversations have at most %d participants, you included", store.MaxConversationParticipants))
+		return
+	}
+	if len(ids) == 1 && payload.Title != nil {
+		app.badRequestResponse(w, r, errors.New("only group conversations have a title"))
+		return
+	}
+
+	ctx := r.Context()
+
+	conversation := &store.Conversation{Title: payload.Title, CreatedBy: &user.ID}
+
+	status := http.StatusCreated
+	if err := app.store.Conversations.Create(ctx, conversation, ids); err != nil {
+		switch err {
+		case store.ErrConflict:
+			status = http.StatusOK
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+			return
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	conversation, err := app.store.Conversations.GetByID(ctx, conversation.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, status, conversation); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetConversation godoc
+//
+//	@Summary		Fetches a conversation
+//	@Description	Fetches a conversation of the authenticated user with the read cursor of every participant
+//	@Tags			messages
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Success		200				{object}	store.Conversation
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID} [get]
+func (app *application) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getConversationFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetMessages godoc
+//
+//	@Summary		Lists messages
+//	@Description	Lists the messages of a conversation, newest first. Pass next_cursor as before to page back.
+//	@Description	Messages of users that have a block with the authenticated user are left out.
+//	@Tags			messages
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Param			before			query		int	false	"Only messages older than this message ID"
+//	@Param			limit			query		int	false	"Number of messages to return"	default(20)
+//	@Success		200				{object}	MessagesPage
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [get]
+func (app *application) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r)
+
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var before int64
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = strconv.ParseInt(b, 10, 64)
+		if err != nil || before < 1 {
+			app.badRequestResponse(w, r, errors.New("before must be a message ID"))
+			return
+		}
+	}
+
+	user := getUserFromContext(r)
+
+	messages, err := app.store.Messages.GetByConversationID(r.Context(), conversation.ID, user.ID, before, fq.Limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	page := MessagesPage{Messages: messages}
+	if len(messages) == fq.Limit {
+		page.NextCursor = &messages[len(messages)-1].ID
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SendMessage godoc
+//
+//	@Summary		Sends a message
+//	@Description	Sends a message to a conversation. In a 1:1 conversation the other participant must still be
+//	@Description	reachable: no block either way, and a private account must be followed unless they wrote back.
+//	@Tags			messages
+//	@Accept			json
+//	@Produce		json
+//	@Param			conversationID	path		int					true	"Conversation ID"
+//	@Param			payload			body		SendMessagePayload	true	"Message"
+//	@Success		201				{object}	store.Message
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [post]
+func (app *application) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r)
+
+	var payload SendMessagePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	message := &store.Message{
+		ConversationID: conversation.ID,
+		SenderID:       user.ID,
+		Content:        payload.Content,
+		Sender:         store.User{ID: user.ID, UserName: user.UserName},
+	}
+
+	if err := app.store.Messages.Create(r.Context(), message); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// like the message listing, participants with a block with the sender
+	// don't get it
+	for _, p := range conversation.Participants {
+		if p.UserID != user.ID && !app.blockedWith(r.Context(), user, p.UserID) {
+			app.publish(userTopic(p.UserID), "message.created", message)
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, message); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkConversationRead godoc
+//
+//	@Summary		Marks a conversation as read
+//	@Description	Moves the read cursor of the authenticated user up to a message. The other participants are
+//	@Description	sent a conversation.read event with the new cursor.
+//	@Tags			messages
+//	@Accept			json
+//	@Param			conversationID	path	int							true	"Conversation ID"
+//	@Param			payload			body	MarkConversationReadPayload	true	"Last read message"
+//	@Success		204				"Conversation marked as read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/read [put]
+func (app *application) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	conversation := getConversationFromCtx(r)
+
+	var payload MarkConversationReadPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Conversations.MarkRead(r.Context(), conversation.ID, user.ID, payload.MessageID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	receipt := map[string]int64{
+		"conversation_id":      conversation.ID,
+		"user_id":              user.ID,
+		"last_read_message_id": payload.MessageID,
+	}
+	for _, p := range conversation.Participants {
+		if p.UserID != user.ID && !app.blockedWith(r.Context(), user, p.UserID) {
+			app.publish(userTopic(p.UserID), "conversation.read", receipt)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) conversationContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		conversation, err := app.store.Conversations.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, conversationCtx, conversation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(r *http.Request) *store.Conversation {
+	conversation, _ := r.Context().Value(conversationCtx).(*store.Conversation)
+	return conversation
+}
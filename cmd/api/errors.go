@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 )
 
@@ -51,3 +52,33 @@ func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r
 
 	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 }
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("precondition failed", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusPreconditionFailed, "the resource has changed, fetch it again")
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("precondition required", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusPreconditionRequired, "the If-Match header is required")
+}
+
+func (app *application) tooManyRequestsResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("too many requests", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusTooManyRequests, err.Error())
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, maxBytes int64) {
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the file must be at most %d bytes", maxBytes))
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("unsupported media type", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"social/internal/store"
+	"strings"
+)
+
+// postETag derives a strong ETag from the post version, which writes return
+// and If-Match is checked against. It only changes when the post itself is
+// edited, not when its comments, poll or quoted post do, so reads are tagged
+// by jsonResponseWithETag instead.
+func postETag(post *store.Post) string {
+	return fmt.Sprintf(`"%d"`, post.Version)
+}
+
+// jsonResponseWithETag writes data like jsonResponse, tagged with a weak ETag
+// hashing the whole body, and answers 304 when If-None-Match lists it.
+func (app *application) jsonResponseWithETag(w http.ResponseWriter, r *http.Request, status int, data any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(&dataEnvelope{Data: data}); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagListContains(inm, strings.TrimPrefix(etag, "W/"), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// etagListContains reports whether the If-Match or If-None-Match header value
+// lists etag. Weak tags only match when weak is set, as If-Match requires the
+// strong comparison and If-None-Match the weak one.
+func etagListContains(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPostIfMatch enforces the If-Match precondition of a write to post,
+// writing 428 when the header is required but missing and 412 when it no
+// longer matches. It reports whether the request may go on.
+func (app *application) checkPostIfMatch(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		if app.config.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	if !etagListContains(ifMatch, postETag(post), false) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"social/internal/events"
+)
+
+const (
+	// publishWorkers is how many published posts are fanned out at once.
+	publishWorkers = 4
+	// publishQueueSize is how many published posts wait for a worker before
+	// further ones are dropped.
+	publishQueueSize = 256
+)
+
+// subscribeEvents hooks the handlers that keep this replica in sync with
+// writes made anywhere in the cluster. Fanning out published posts queries
+// the database, so it runs on workers until ctx is done rather than on the
+// listener, which would hold back every event behind it.
+func (app *application) subscribeEvents(ctx context.Context, l *events.Listener) {
+	published := make(chan int64, publishQueueSize)
+	for i := 0; i < publishWorkers; i++ {
+		go app.runPublishWorker(ctx, published)
+	}
+
+	l.Subscribe(events.PostPublished, func(_ context.Context, e events.Event) {
+		app.onPostPublished(e, published)
+	})
+	l.Subscribe(events.Streamed, app.onStreamed)
+	l.Subscribe(events.Reconnected, func(ctx context.Context, e events.Event) {
+		app.logger.Warnw("events listener reconnected, events sent meanwhile were missed")
+	})
+}
+
+// onPostPublished queues a newly published post for the publish workers. Live
+// pushes are best effort, so with the queue full the post is only left out of
+// them and still shows up in feeds.
+func (app *application) onPostPublished(e events.Event, published chan<- int64) {
+	var ev events.PostEvent
+	if err := json.Unmarshal(e.Data, &ev); err != nil {
+		app.logger.Errorw("malformed post event", "error", err)
+		return
+	}
+
+	select {
+	case published <- ev.ID:
+	default:
+		app.logger.Warnw("publish queue full, post not pushed live", "post", ev.ID)
+	}
+}
+
+// runPublishWorker fans the posts queued on published out to the stream hub,
+// so followers connected to any replica see them, whichever one published
+// them.
+func (app *application) runPublishWorker(ctx context.Context, published <-chan int64) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-published:
+			post, err := app.store.Posts.GetById(ctx, id)
+			if err != nil {
+				app.logger.Errorw("error fetching published post", "post", id, "error", err)
+				continue
+			}
+
+			app.publishPost(ctx, post)
+		}
+	}
+}
+
+// onStreamed hands an event relayed by publish to this replica's hub.
+func (app *application) onStreamed(ctx context.Context, e events.Event) {
+	var ev events.StreamEvent
+	if err := json.Unmarshal(e.Data, &ev); err != nil {
+		app.logger.Errorw("malformed stream event", "error", err)
+		return
+	}
+
+	app.publishLocal(ev.Topic, ev.Type, ev.Data)
+}
@@ -3,17 +3,52 @@ package main
 import (
 	"net/http"
 	"social/internal/store"
+	"social/internal/tags"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
 )
 
+// parseFeedQuery reads the pagination, filtering and sorting parameters shared
+// by every post timeline.
+func parseFeedQuery(r *http.Request) (store.PaginatedFeedQuery, error) {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		return fq, err
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		return fq, err
+	}
+
+	// stored tags are normalized, so the filter has to be too
+	if len(fq.Tags) > 0 {
+		fq.Tags, err = tags.NormalizeAll(fq.Tags)
+		if err != nil {
+			return fq, err
+		}
+	}
+
+	return fq, nil
+}
+
 // get user feed godoc
 //
 //	@Summary		Get User Feed
 //	@Description	Retrieves the feed for a specific user with pagination and sorting options
+//	@Description	Posts reposted by followed users carry reposted_by, each post is listed once per feed
 //	@Tags			feed
 //	@Produce		json
 //	@Param			limit	query		int		false	"Number of posts to return"	default(20)
 //	@Param			offset	query		int		false	"Number of posts to skip"	default(0)
 //	@Param			sort	query		string	false	"Sort order: asc or desc"	default(desc)	Enum(asc, desc)
+//	@Param			blend	query		bool	false	"List explore posts after the followed ones"
 //	@Success		200		{object}	[]store.PostWithMetaData
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
@@ -22,34 +57,152 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	// pagination, filtering and sorting
 
-	fq := store.PaginatedFeedQuery{
-		Limit:  20,
-		Offset: 0,
-		Sort:   "desc",
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	fq, err := fq.Parse(r)
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+		app.internalServerError(w, r, err)
+
+	}
+
+}
+
+// get public timeline godoc
+//
+//	@Summary		Get the public timeline
+//	@Description	Retrieves the public posts of every user, without the authors the authenticated user blocked or muted
+//	@Tags			explore
+//	@Produce		json
+//	@Param			limit	query		int		false	"Number of posts to return"	default(20)
+//	@Param			offset	query		int		false	"Number of posts to skip"	default(0)
+//	@Param			sort	query		string	false	"Sort order: asc or desc"	default(desc)	Enum(asc, desc)
+//	@Param			tags	query		string	false	"Comma separated tags"
+//	@Param			search	query		string	false	"Search in title and content"
+//	@Param			since	query		string	false	"Posts created at or after, YYYY-MM-DD HH:MM:SS"
+//	@Param			until	query		string	false	"Posts created at or before, YYYY-MM-DD HH:MM:SS"
+//	@Success		200		{object}	[]store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/explore [get]
+func (app *application) getPublicTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFeedQuery(r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	if err := Validate.Struct(fq); err != nil {
+
+	user := getUserFromContext(r)
+
+	posts, err := app.store.Posts.GetPublicTimeline(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// get user posts godoc
+//
+//	@Summary		Get a user's posts
+//	@Description	Retrieves the posts written by a user that the authenticated user is allowed to see
+//	@Tags			feed
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Number of posts to return"	default(20)
+//	@Param			offset	query		int		false	"Number of posts to skip"	default(0)
+//	@Param			sort	query		string	false	"Sort order: asc or desc"	default(desc)	Enum(asc, desc)
+//	@Param			tags	query		string	false	"Comma separated tags"
+//	@Param			search	query		string	false	"Search in title and content"
+//	@Param			since	query		string	false	"Posts created at or after, YYYY-MM-DD HH:MM:SS"
+//	@Param			until	query		string	false	"Posts created at or before, YYYY-MM-DD HH:MM:SS"
+//	@Success		200		{object}	[]store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	authorID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
+	}
 
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	if _, err := app.store.Users.GetById(ctx, authorID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(5), fq)
+	posts, err := app.store.Posts.GetUserPosts(ctx, authorID, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
 		app.internalServerError(w, r, err)
+	}
+}
+
+// get drafts godoc
+//
+//	@Summary		Get my drafts
+//	@Description	Retrieves the draft and scheduled posts of the authenticated user
+//	@Tags			feed
+//	@Produce		json
+//	@Param			limit	query		int	false	"Number of posts to return"	default(20)
+//	@Param			offset	query		int	false	"Number of posts to skip"	default(0)
+//	@Success		200		{object}	[]store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
+	user := getUserFromContext(r)
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
+	if err := app.jsonResponse(w, http.StatusOK, drafts); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
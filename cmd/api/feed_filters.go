@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type feedFilterKey string
+
+const feedFilterCtx feedFilterKey = "feedFilter"
+
+type CreateFeedFilterPayload struct {
+	Kind      string     `json:"kind" validate:"required,oneof=keyword tag"`
+	Value     string     `json:"value" validate:"required,max=100"`
+	WholeWord bool       `json:"whole_word"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// UpdateFeedFilterPayload leaves out the fields that don't change. An omitted
+// and a null expires_at can't be told apart, so NeverExpires clears it.
+type UpdateFeedFilterPayload struct {
+	Kind         *string    `json:"kind" validate:"omitempty,oneof=keyword tag"`
+	Value        *string    `json:"value" validate:"omitempty,max=100"`
+	WholeWord    *bool      `json:"whole_word"`
+	ExpiresAt    *time.Time `json:"expires_at" validate:"excluded_with=NeverExpires"`
+	NeverExpires bool       `json:"never_expires"`
+}
+
+// normalizeFeedFilter lowercases the filter value, which the feed query relies on,
+// and drops a leading '#' from tag filters.
+func normalizeFeedFilter(f *store.FeedFilter) error {
+	f.Value = strings.ToLower(strings.TrimSpace(f.Value))
+	if f.Kind == store.FeedFilterTag {
+		f.Value = strings.TrimPrefix(f.Value, "#")
+	}
+
+	if f.Value == "" {
+		return errors.New("filter value can't be empty")
+	}
+
+	return nil
+}
+
+// validateFilterExpiry checks an expires_at being set. Filters that already
+// expired can still be edited, or revived with a new expires_at.
+func validateFilterExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+	return nil
+}
+
+// GetFeedFilters godoc
+//
+//	@Summary		Lists feed filters
+//	@Description	Lists the keyword and tag filters of the authenticated user
+//	@Tags			feed
+//	@Produce		json
+//	@Success		200	{object}	[]store.FeedFilter
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/filters [get]
+func (app *application) getFeedFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	filters, err := app.store.FeedFilters.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, filters); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateFeedFilter godoc
+//
+//	@Summary		Creates a feed filter
+//	@Description	Hides feed posts containing a keyword or tag, optionally until expires_at
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateFeedFilterPayload	true	"Filter"
+//	@Success		201		{object}	store.FeedFilter
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/filters [post]
+func (app *application) createFeedFilterHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateFeedFilterPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	filter := &store.FeedFilter{
+		UserID:    user.ID,
+		Kind:      payload.Kind,
+		Value:     payload.Value,
+		WholeWord: payload.WholeWord,
+		ExpiresAt: payload.ExpiresAt,
+	}
+
+	if err := validateFilterExpiry(payload.ExpiresAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := normalizeFeedFilter(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.FeedFilters.Create(r.Context(), filter); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, filter); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateFeedFilter godoc
+//
+//	@Summary		Updates a feed filter
+//	@Description	Updates a feed filter of the authenticated user. never_expires clears expires_at, making
+//	@Description	the filter permanent, which also revives an expired one.
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			filterID	path		int						true	"Filter ID"
+//	@Param			payload		body		UpdateFeedFilterPayload	true	"Filter"
+//	@Success		200			{object}	store.FeedFilter
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/filters/{filterID} [patch]
+func (app *application) updateFeedFilterHandler(w http.ResponseWriter, r *http.Request) {
+	filter := getFeedFilterFromCtx(r)
+
+	var payload UpdateFeedFilterPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Kind != nil {
+		filter.Kind = *payload.Kind
+	}
+	if payload.Value != nil {
+		filter.Value = *payload.Value
+	}
+	if payload.WholeWord != nil {
+		filter.WholeWord = *payload.WholeWord
+	}
+	if payload.ExpiresAt != nil {
+		if err := validateFilterExpiry(payload.ExpiresAt); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		filter.ExpiresAt = payload.ExpiresAt
+	}
+	if payload.NeverExpires {
+		filter.ExpiresAt = nil
+	}
+
+	if err := normalizeFeedFilter(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.FeedFilters.Update(r.Context(), filter); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, filter); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteFeedFilter godoc
+//
+//	@Summary		Deletes a feed filter
+//	@Description	Deletes a feed filter of the authenticated user
+//	@Tags			feed
+//	@Param			filterID	path	int	true	"Filter ID"
+//	@Success		204			"Filter deleted"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/filters/{filterID} [delete]
+func (app *application) deleteFeedFilterHandler(w http.ResponseWriter, r *http.Request) {
+	filter := getFeedFilterFromCtx(r)
+
+	if err := app.store.FeedFilters.Delete(r.Context(), filter.ID, filter.UserID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) feedFilterContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "filterID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		filter, err := app.store.FeedFilters.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, feedFilterCtx, filter)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getFeedFilterFromCtx(r *http.Request) *store.FeedFilter {
+	filter, _ := r.Context().Value(feedFilterCtx).(*store.FeedFilter)
+	return filter
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetFollowRequests godoc
+//
+//	@Summary		Lists incoming follow requests
+//	@Description	Lists follow requests sent to the authenticated user, pending ones by default
+//	@Tags			users
+//	@Produce		json
+//	@Param			status	query		string	false	"Request status"	default(pending)	Enum(pending, accepted, rejected)
+//	@Success		200		{object}	[]store.FollowRequest
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = store.FollowRequestPending
+	case store.FollowRequestPending, store.FollowRequestAccepted, store.FollowRequestRejected:
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("invalid status %q", status))
+		return
+	}
+
+	requests, err := app.store.FollowRequests.GetIncoming(r.Context(), user.ID, status)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, requests); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AcceptFollowRequest godoc
+//
+//	@Summary		Accepts a follow request
+//	@Description	Accepts a pending follow request, making the requester a follower
+//	@Tags			users
+//	@Param			followerID	path	int	true	"Requesting user ID"
+//	@Success		204			"Follow request accepted"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{followerID}/accept [put]
+func (app *application) acceptFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, func(ctx context.Context, userID, followerID int64) error {
+		if err := app.store.FollowRequests.Accept(ctx, userID, followerID); err != nil {
+			return err
+		}
+
+		app.deliverWebhook(ctx, userID, store.WebhookFollow, followWebhookPayload(followerID, userID))
+		return nil
+	})
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Rejects a pending follow request
+//	@Tags			users
+//	@Param			followerID	path	int	true	"Requesting user ID"
+//	@Success		204			"Follow request rejected"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{followerID}/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.FollowRequests.Reject)
+}
+
+func (app *application) resolveFollowRequest(w http.ResponseWriter, r *http.Request, resolve func(ctx context.Context, userID, followerID int64) error) {
+	user := getUserFromContext(r)
+
+	followerID, err := strconv.ParseInt(chi.URLParam(r, "followerID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := resolve(r.Context(), user.ID, followerID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -34,9 +34,11 @@ func writeJSONError(w http.ResponseWriter, status int, message string) error {
 	}
 	return writeJSON(w, status, &envelope{Error: message})
 }
+
+type dataEnvelope struct {
+	Data any `json:"data"`
+}
+
 func (app *application) jsonResponse(w http.ResponseWriter, status int, data any) error {
-	type envelope struct {
-		Data any `json:"data"`
-	}
-	return writeJSON(w, status, &envelope{Data: data})
+	return writeJSON(w, status, &dataEnvelope{Data: data})
 }
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"social/internal/auth"
 	"social/internal/db"
 	"social/internal/env"
+	"social/internal/events"
 	"social/internal/mailer"
+	"social/internal/objectstore"
 	"social/internal/store"
+	"social/internal/stream"
+	"social/internal/webhooks"
 	"time"
 
 	"go.uber.org/zap"
@@ -42,7 +47,47 @@ func main() {
 			maxIdleConns: env.GetInt("DB_MAX_IDLE_CONNS", 30),
 			maxIdleTime:  env.GetString("DB_MAX_IDLE_TIME", "15m"),
 		},
-		env: env.GetString("ENV", "development"),
+		env:            env.GetString("ENV", "development"),
+		maxPinnedPosts: env.GetInt("MAX_PINNED_POSTS", 3),
+		requireIfMatch: env.GetBool("REQUIRE_IF_MATCH", false),
+		scheduler: schedulerConfig{
+			interval:  time.Second * time.Duration(env.GetInt("SCHEDULER_INTERVAL_SECONDS", 30)),
+			batchSize: env.GetInt("SCHEDULER_BATCH_SIZE", 100),
+		},
+		trash: trashConfig{
+			retention:     time.Hour * 24 * time.Duration(env.GetInt("TRASH_RETENTION_DAYS", 30)),
+			purgeInterval: time.Minute * time.Duration(env.GetInt("TRASH_PURGE_INTERVAL_MINUTES", 60)),
+		},
+		trending: trendingConfig{
+			interval: time.Minute * time.Duration(env.GetInt("TRENDING_INTERVAL_MINUTES", 5)),
+			size:     env.GetInt("TRENDING_SIZE", 50),
+		},
+		stream: streamConfig{
+			history:   env.GetInt("STREAM_HISTORY", 1000),
+			buffer:    env.GetInt("STREAM_BUFFER", 64),
+			heartbeat: time.Second * time.Duration(env.GetInt("STREAM_HEARTBEAT_SECONDS", 15)),
+		},
+		ws: wsConfig{
+			maxConnsPerUser: env.GetInt("WS_MAX_CONNS_PER_USER", 5),
+			pingInterval:    time.Second * time.Duration(env.GetInt("WS_PING_INTERVAL_SECONDS", 30)),
+		},
+		media: mediaConfig{
+			dir:                 env.GetString("MEDIA_DIR", "./uploads"),
+			baseURL:             env.GetString("MEDIA_BASE_URL", "http://localhost:8080/v1/media/files"),
+			maxBytes:            int64(env.GetInt("MEDIA_MAX_BYTES", 10<<20)),
+			maxPixels:           env.GetInt("MEDIA_MAX_PIXELS", 40_000_000),
+			thumbnailSize:       env.GetInt("MEDIA_THUMBNAIL_SIZE", 400),
+			unattachedRetention: time.Hour * time.Duration(env.GetInt("MEDIA_UNATTACHED_RETENTION_HOURS", 24)),
+			purgeBatchSize:      env.GetInt("MEDIA_PURGE_BATCH_SIZE", 100),
+		},
+		webhooks: webhookConfig{
+			interval:    time.Second * time.Duration(env.GetInt("WEBHOOK_INTERVAL_SECONDS", 5)),
+			batchSize:   env.GetInt("WEBHOOK_BATCH_SIZE", 50),
+			timeout:     time.Second * time.Duration(env.GetInt("WEBHOOK_TIMEOUT_SECONDS", 10)),
+			maxAttempts: env.GetInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			maxFailures: env.GetInt("WEBHOOK_MAX_FAILURES", 20),
+			retention:   time.Hour * 24 * time.Duration(env.GetInt("WEBHOOK_LOG_RETENTION_DAYS", 30)),
+		},
 		mail: mailConfig{
 			exp:       time.Hour * 24 * 3, //3days
 			fromEmail: env.GetString("FROM_EMAIL", ""),
@@ -68,6 +113,20 @@ func main() {
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	// tickers panic on a zero or negative interval
+	for name, interval := range map[string]time.Duration{
+		"SCHEDULER_INTERVAL_SECONDS":   cfg.scheduler.interval,
+		"TRASH_PURGE_INTERVAL_MINUTES": cfg.trash.purgeInterval,
+		"TRENDING_INTERVAL_MINUTES":    cfg.trending.interval,
+		"STREAM_HEARTBEAT_SECONDS":     cfg.stream.heartbeat,
+		"WS_PING_INTERVAL_SECONDS":     cfg.ws.pingInterval,
+		"WEBHOOK_INTERVAL_SECONDS":     cfg.webhooks.interval,
+	} {
+		if interval <= 0 {
+			logger.Fatalf("%s must be positive", name)
+		}
+	}
+
 	//database
 
 	db, err := db.New(
@@ -89,6 +148,11 @@ func main() {
 
 	mailer := mailer.NewSendgrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
+	objects, err := objectstore.NewLocal(cfg.media.dir, cfg.media.baseURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	jwtAuthenticator := auth.NewJWTAuntenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
 
 	app := &application{
@@ -97,8 +161,28 @@ func main() {
 		logger:        logger,
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
+		hub:           stream.NewMemoryHub(cfg.stream.history, cfg.stream.buffer),
+		events:        db,
+		webhookSender: webhooks.NewSender(cfg.webhooks.timeout),
+		objects:       objects,
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener := events.NewListener(cfg.db.addr, logger)
+	app.subscribeEvents(ctx, listener)
+	go func() {
+		if err := listener.Run(ctx); err != nil {
+			logger.Errorw("events listener stopped", "error", err)
+		}
+	}()
+
+	go app.runPostScheduler(ctx)
+	go app.runTrashPurger(ctx)
+	go app.runTrendingAggregator(ctx)
+	go app.runWebhookDispatcher(ctx)
+
 	mux := app.mount()
 
 	logger.Fatal(app.run(mux))
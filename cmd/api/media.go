@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"social/internal/media"
+	"social/internal/store"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type mediaKey string
+
+const mediaCtx mediaKey = "media"
+
+type UpdateMediaPayload struct {
+	AltText string `json:"alt_text" validate:"max=1000"`
+}
+
+// UploadMedia godoc
+//
+//	@Summary		Uploads an image
+//	@Description	Uploads a JPEG, PNG or GIF image as multipart form data, with an optional alt text. The type is
+//	@Description	sniffed from the file content. The upload can then be attached to a post with attachment_ids;
+//	@Description	uploads left unattached are deleted after a while.
+//	@Tags			media
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file		formData	file	true	"Image"
+//	@Param			alt_text	formData	string	false	"Alt text"
+//	@Success		201			{object}	store.Media
+//	@Failure		400			{object}	error
+//	@Failure		413			{object}	error	"File too large"
+//	@Failure		415			{object}	error	"Unsupported media type"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := app.config.media.maxBytes
+
+	// room for the multipart envelope and the alt text
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<16)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			app.payloadTooLargeResponse(w, r, maxBytes)
+			return
+		}
+		app.badRequestResponse(w, r, errors.New("a file form field is required"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		app.payloadTooLargeResponse(w, r, maxBytes)
+		return
+	}
+
+	altText := strings.TrimSpace(r.FormValue("alt_text"))
+	if err := Validate.Struct(UpdateMediaPayload{AltText: altText}); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	img, err := media.Decode(data, app.config.media.maxPixels)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, media.ErrUnsupportedType)
+		case errors.Is(err, media.ErrTooManyPixels):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	thumb, thumbType, err := img.Thumbnail(app.config.media.thumbnailSize)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	name := fmt.Sprintf("media/%d/%s", user.ID, uuid.NewString())
+	m := &store.Media{
+		UserID:       user.ID,
+		StorageKey:   name + img.Ext,
+		ThumbnailKey: name + "_thumb" + extension(thumbType),
+		ContentType:  img.ContentType,
+		Size:         int64(len(data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		AltText:      altText,
+	}
+	m.URL = app.objects.URL(m.StorageKey)
+	m.ThumbnailURL = app.objects.URL(m.ThumbnailKey)
+
+	if err := app.objects.Put(ctx, m.StorageKey, bytes.NewReader(data), m.ContentType); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.objects.Put(ctx, m.ThumbnailKey, bytes.NewReader(thumb), thumbType); err != nil {
+		app.deleteMediaObjects(m)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Media.Create(ctx, m); err != nil {
+		app.deleteMediaObjects(m)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, m); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func extension(contentType string) string {
+	if contentType == "image/png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// GetMedia godoc
+//
+//	@Summary		Fetches an upload
+//	@Description	Fetches an image uploaded by the authenticated user
+//	@Tags			media
+//	@Produce		json
+//	@Param			mediaID	path		int	true	"Media ID"
+//	@Success		200		{object}	store.Media
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [get]
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getMediaFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateMedia godoc
+//
+//	@Summary		Updates the alt text of an upload
+//	@Description	Sets the alt text of an image uploaded by the authenticated user, attached to a post or not
+//	@Tags			media
+//	@Accept			json
+//	@Produce		json
+//	@Param			mediaID	path		int					true	"Media ID"
+//	@Param			payload	body		UpdateMediaPayload	true	"Alt text"
+//	@Success		200		{object}	store.Media
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [patch]
+func (app *application) updateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	m := getMediaFromCtx(r)
+
+	var payload UpdateMediaPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload.AltText = strings.TrimSpace(payload.AltText)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Media.UpdateAltText(r.Context(), m.ID, m.UserID, payload.AltText); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	m.AltText = payload.AltText
+
+	if err := app.jsonResponse(w, http.StatusOK, m); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteMedia godoc
+//
+//	@Summary		Deletes an upload
+//	@Description	Deletes an image uploaded by the authenticated user that isn't attached to a post
+//	@Tags			media
+//	@Param			mediaID	path	int	true	"Media ID"
+//	@Success		204		"Upload deleted"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Attached to a post"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [delete]
+func (app *application) deleteMediaHandler(w http.ResponseWriter, r *http.Request) {
+	m := getMediaFromCtx(r)
+
+	if m.PostID != nil {
+		app.ConflictResponse(w, r, errors.New("the upload is attached to a post"))
+		return
+	}
+
+	if err := app.store.Media.Delete(r.Context(), m.ID, m.UserID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.deleteMediaObjects(m)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteMediaObjects removes the image and thumbnail of m from the object
+// store. Failures are logged, leaving the objects behind.
+func (app *application) deleteMediaObjects(m *store.Media) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, key := range []string{m.StorageKey, m.ThumbnailKey} {
+		if err := app.objects.Delete(ctx, key); err != nil {
+			app.logger.Errorw("error deleting media object", "media", m.ID, "key", key, "error", err)
+		}
+	}
+}
+
+// purgeMedia deletes the uploads never attached to a post within the
+// retention window, and those of posts purged from the trash.
+func (app *application) purgeMedia(ctx context.Context) {
+	before := time.Now().Add(-app.config.media.unattachedRetention)
+
+	var purged int
+	for {
+		orphans, err := app.store.Media.PurgeOrphans(ctx, before, app.config.media.purgeBatchSize)
+		if err != nil {
+			app.logger.Errorw("error purging media", "error", err)
+			return
+		}
+
+		for i := range orphans {
+			app.deleteMediaObjects(&orphans[i])
+		}
+		purged += len(orphans)
+
+		if len(orphans) < app.config.media.purgeBatchSize {
+			break
+		}
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged media", "media", purged)
+	}
+}
+
+func (app *application) mediaContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		m, err := app.store.Media.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, mediaCtx, m)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getMediaFromCtx(r *http.Request) *store.Media {
+	m, _ := r.Context().Value(mediaCtx).(*store.Media)
+	return m
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"social/internal/mentions"
+	"social/internal/store"
+)
+
+// parseMentions finds the mentions of content, which the store then resolves
+// to users.
+func parseMentions(content string) []store.Mention {
+	matches := mentions.Parse(content)
+
+	out := make([]store.Mention, len(matches))
+	for i, m := range matches {
+		out[i] = store.Mention{Username: m.Username, Start: m.Start, End: m.End}
+	}
+
+	return out
+}
+
+// notifyMentions notifies every user in mentioned once, except users already
+// mentioned before an edit.
+func (app *application) notifyMentions(ctx context.Context, actorID int64, mentioned, previous []store.Mention, postID, commentID *int64) {
+	skip := map[int64]bool{}
+	for _, m := range previous {
+		skip[m.UserID] = true
+	}
+
+	for _, m := range mentioned {
+		if skip[m.UserID] {
+			continue
+		}
+		skip[m.UserID] = true
+
+		app.notify(ctx, m.UserID, actorID, store.NotificationMention, postID, commentID)
+	}
+}
+
+// notifyPostMentions notifies the users mentioned in post, but only once it
+// is published: drafts and scheduled posts notify when they publish. previous
+// holds the mentions already notified, nil when the post wasn't published
+// before the write.
+func (app *application) notifyPostMentions(ctx context.Context, actorID int64, post *store.Post, previous []store.Mention) {
+	if post.Status != store.PostStatusPublished {
+		return
+	}
+
+	app.notifyMentions(ctx, actorID, post.Mentions, previous, &post.ID, nil)
+}
@@ -8,7 +8,9 @@ import (
 	"social/internal/store"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -25,23 +27,9 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token := parts[1]
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-		}
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
-
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-
-		}
 		ctx := r.Context()
 
-		user, err := app.store.Users.GetById(ctx, userID)
+		user, err := app.userFromToken(ctx, parts[1])
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
@@ -53,6 +41,22 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// userFromToken returns the user a JWT was issued to, once validated.
+func (app *application) userFromToken(ctx context.Context, token string) (*store.User, error) {
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.store.Users.GetById(ctx, userID)
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -122,6 +126,26 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
+func (app *application) checkCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromContext(r)
+		comment := getCommentFromCtx(r)
+
+		allowed, err := app.isOwnerOrRole(r.Context(), user, comment.UserID, requiredRole)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) checkRoleprecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 
 	role, err := app.store.Roles.GetByName(ctx, roleName)
@@ -130,3 +154,35 @@ func (app *application) checkRoleprecedence(ctx context.Context, user *store.Use
 	}
 	return user.Role.Level >= role.Level, nil
 }
+
+// isOwnerOrRole reports whether user owns the resource or has at least requiredRole.
+func (app *application) isOwnerOrRole(ctx context.Context, user *store.User, ownerID int64, requiredRole string) (bool, error) {
+	if user.ID == ownerID {
+		return true, nil
+	}
+	return app.checkRoleprecedence(ctx, user, requiredRole)
+}
+
+// longLivedPaths are the endpoints holding their connection open, which end
+// when the client goes away rather than after the request timeout.
+var longLivedPaths = map[string]bool{
+	"/v1/stream": true,
+	"/v1/ws":     true,
+}
+
+// timeoutMiddleware is middleware.Timeout, skipped for longLivedPaths.
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	withTimeout := middleware.Timeout(timeout)
+
+	return func(next http.Handler) http.Handler {
+		timed := withTimeout(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if longLivedPaths[strings.TrimSuffix(r.URL.Path, "/")] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timed.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// notify stores a notification for userID about something actorID did.
+// Nobody is notified of their own actions. Failures are logged rather than
+// failing the request that triggered the notification.
+func (app *application) notify(ctx context.Context, userID, actorID int64, kind string, postID, commentID *int64) {
+	if userID == actorID {
+		return
+	}
+
+	n := &store.Notification{
+		UserID:    userID,
+		ActorID:   actorID,
+		Type:      kind,
+		PostID:    postID,
+		CommentID: commentID,
+	}
+	if err := app.store.Notifications.Create(ctx, n); err != nil {
+		app.logger.Errorw("error creating notification", "type", kind, "user", userID, "error", err)
+		return
+	}
+
+	// pushed only if the listing would show it, blocks and mutes included
+	visible, err := app.store.Notifications.IsVisible(ctx, n.ID)
+	if err != nil {
+		app.logger.Errorw("error checking notification visibility", "notification", n.ID, "error", err)
+		return
+	}
+	if visible {
+		app.publish(userTopic(userID), "notification", n)
+	}
+}
+
+// get notifications godoc
+//
+//	@Summary		Get notifications
+//	@Description	Retrieves the notifications of the authenticated user grouped by type and target, latest first
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			limit	query		int		false	"Number of groups to return"	default(20)
+//	@Param			offset	query		int		false	"Number of groups to skip"		default(0)
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Success		200		{object}	[]store.NotificationGroup
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var unread bool
+	if u := r.URL.Query().Get("unread"); u != "" {
+		unread, err = strconv.ParseBool(u)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("unread must be a boolean"))
+			return
+		}
+	}
+
+	user := getUserFromContext(r)
+
+	groups, err := app.store.Notifications.GetByUserID(r.Context(), user.ID, unread, fq.Limit, fq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, groups); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// get unread notifications count godoc
+//
+//	@Summary		Count unread notifications
+//	@Description	Returns how many unread notifications the authenticated user has, cheap enough to poll
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]int
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/unread-count [get]
+func (app *application) getUnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	count, err := app.store.Notifications.CountUnread(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, map[string]int{"count": count}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// mark notification read godoc
+//
+//	@Summary		Mark a notification read
+//	@Description	Marks a notification read, along with the older ones grouped with it
+//	@Tags			notifications
+//	@Param			notificationID	path	int	true	"Notification or group ID"
+//	@Success		204				"Notification marked read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{notificationID}/read [put]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "notificationID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Notifications.MarkRead(r.Context(), user.ID, id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mark all notifications read godoc
+//
+//	@Summary		Mark all notifications read
+//	@Description	Marks every notification of the authenticated user read
+//	@Tags			notifications
+//	@Success		204	"Notifications marked read"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read-all [put]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	if err := app.store.Notifications.MarkAllRead(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"social/internal/store"
+)
+
+// PinPost godoc
+//
+//	@Summary		Pins a post
+//	@Description	Pins a post to the top of its author's profile timeline
+//	@Tags			post
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Post pinned"
+//	@Failure		400		{object}	error	"Pinned posts limit reached"
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Post already pinned"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [put]
+func (app *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if err := app.store.Pins.Pin(r.Context(), post.UserID, post.ID, app.config.maxPinnedPosts); err != nil {
+		switch {
+		case errors.Is(err, store.ErrPinLimit):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpins a post
+//	@Description	Removes a post from its author's pinned posts
+//	@Tags			post
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Post unpinned"
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/unpin [put]
+func (app *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if err := app.store.Pins.Unpin(r.Context(), post.UserID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
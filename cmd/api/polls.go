@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"social/internal/store"
+	"strings"
+	"time"
+)
+
+type CreatePollPayload struct {
+	Options           []string   `json:"options" validate:"required,min=2,max=10,dive,required,max=100"`
+	Multiple          bool       `json:"multiple"`
+	ClosesAt          *time.Time `json:"closes_at"`
+	ResultsVisibility string     `json:"results_visibility" validate:"omitempty,oneof=always after_vote after_close"`
+}
+
+type VotePollPayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=10,dive,gt=0"`
+}
+
+// newPoll checks a poll payload and turns it into the poll stored with the
+// post. A poll must stay open until after the post is published.
+func newPoll(payload *CreatePollPayload, publishAt *time.Time) (*store.Poll, error) {
+	poll := &store.Poll{
+		Multiple:          payload.Multiple,
+		ClosesAt:          payload.ClosesAt,
+		ResultsVisibility: payload.ResultsVisibility,
+	}
+
+	seen := map[string]bool{}
+	for _, label := range payload.Options {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			return nil, errors.New("poll options can't be empty")
+		}
+		if seen[strings.ToLower(label)] {
+			return nil, errors.New("poll options must be unique")
+		}
+		seen[strings.ToLower(label)] = true
+		poll.Options = append(poll.Options, store.PollOption{Label: label})
+	}
+
+	if poll.ClosesAt != nil {
+		opensAt := time.Now()
+		if publishAt != nil {
+			opensAt = *publishAt
+		}
+		if !poll.ClosesAt.After(opensAt) {
+			return nil, errors.New("closes_at must be after the post is published")
+		}
+	} else if poll.ResultsVisibility == store.PollResultsAfterClose {
+		return nil, errors.New("results shown after close need a closes_at")
+	}
+
+	return poll, nil
+}
+
+// attachPoll sets the poll of a post as seen by viewer, if it has one.
+func (app *application) attachPoll(ctx context.Context, viewer *store.User, post *store.Post) error {
+	poll, err := app.store.Polls.Get(ctx, post.ID, viewer.ID)
+	switch err {
+	case nil:
+		post.Poll = poll
+		return nil
+	case store.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+}
+
+// GetPoll godoc
+//
+//	@Summary		Fetches the poll of a post
+//	@Description	Fetches the poll of a post with the choices of the authenticated user. Vote counts are null
+//	@Description	until the poll setting allows them: right away, after voting, or once the poll is closed.
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Poll
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll [get]
+func (app *application) getPollHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	poll, err := app.store.Polls.Get(r.Context(), post.ID, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, poll); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes in a poll
+//	@Description	Casts the ballot of the authenticated user: one option, or several in a multiple choice poll.
+//	@Description	Every user votes once. Returns the poll with the results if the vote reveals them.
+//	@Tags			post
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		VotePollPayload	true	"Chosen options"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error	"Invalid choice or poll closed"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already voted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll/votes [post]
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	var payload VotePollPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestResponse(w, r, errors.New("polls open once the post is published"))
+		return
+	}
+
+	ids := slices.Clone(payload.OptionIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	if err := app.store.Polls.Vote(ctx, post.ID, user.ID, ids); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrPollClosed, store.ErrInvalidPollChoice:
+			app.badRequestResponse(w, r, err)
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	poll, err := app.store.Polls.Get(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, poll); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"net/http"
 	"social/internal/store"
+	"social/internal/tags"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -15,15 +17,43 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title      string     `json:"title" validate:"required,max=100"`
+	Content    string     `json:"content" validate:"required,max=1000"`
+	Tags       []string   `json:"tags" validate:"max=10"`
+	Visibility string     `json:"visibility" validate:"omitempty,oneof=public followers unlisted"`
+	Status     string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time `json:"publish_at"`
+	// QuotedPostID makes the post a quote of another published public post
+	QuotedPostID *int64             `json:"quoted_post_id" validate:"omitempty,gt=0"`
+	Poll         *CreatePollPayload `json:"poll"`
+	// AttachmentIDs are uploads of the author, see uploadMediaHandler, in the
+	// order they are shown
+	AttachmentIDs []int64 `json:"attachment_ids" validate:"omitempty,max=4,unique,dive,gt=0"`
+}
+
+// validatePostStatus checks that scheduled posts, and only those, come with a
+// publish_at in the future.
+func validatePostStatus(status string, publishAt *time.Time) error {
+	if status == store.PostStatusScheduled {
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return errors.New("scheduled posts need a publish_at in the future")
+		}
+		return nil
+	}
+
+	if publishAt != nil {
+		return errors.New("publish_at is only allowed for scheduled posts")
+	}
+
+	return nil
 }
 
 // Create Post
 //
 //	@Summary		Create a post
-//	@Description	Create a new post
+//	@Description	Create a new post, or a quote of another published public post with quoted_post_id, optionally
+//	@Description	with a poll and image attachments uploaded beforehand. The content is Markdown, returned
+//	@Description	rendered to sanitized HTML as content_html
 //	@Tags			post
 //	@Accept			json
 //	@Produce		json
@@ -46,23 +76,64 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := validatePostStatus(payload.Status, payload.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	explicitTags, err := tags.NormalizeAll(payload.Tags)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var poll *store.Poll
+	if payload.Poll != nil {
+		poll, err = newPoll(payload.Poll, payload.PublishAt)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	var attachments []store.Media
+	for _, id := range payload.AttachmentIDs {
+		attachments = append(attachments, store.Media{ID: id})
+	}
+
 	user := getUserFromContext(r)
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:        payload.Title,
+		Content:      payload.Content,
+		Tags:         tags.Merge(explicitTags, payload.Content),
+		ExplicitTags: explicitTags,
+		Mentions:     parseMentions(payload.Content),
+		Visibility:   payload.Visibility,
+		Status:       payload.Status,
+		PublishAt:    payload.PublishAt,
+		QuotedPostID: payload.QuotedPostID,
+		Poll:         poll,
+		Attachments:  attachments,
+		UserID:       user.ID,
 	}
 
 	ctx := r.Context()
 
 	if err := app.store.Posts.Create(ctx, post); err != nil {
-		app.internalServerError(w, r, err)
-
+		switch err {
+		case store.ErrNotShareable, store.ErrInvalidAttachment:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	app.notifyPostMentions(ctx, user.ID, post, nil)
+	app.notifyQuote(ctx, post)
+	app.deliverWebhook(ctx, user.ID, store.WebhookPostCreated, post)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 
@@ -73,14 +144,17 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 // Get Post by ID
 //
 //	@Summary		Fetches post by ID
-//	@Description	Fetches a post by ID
+//	@Description	Fetches a post by ID, with a weak ETag covering the whole response. Writes take the post
+//	@Description	version instead in If-Match, quoted like an ETag.
 //	@Tags			post
 //	@Produce		json
-//	@Param			postID	path		int	true	"Post ID"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			postID			path		int		true	"Post ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	store.Post
+//	@Success		304				"Post not modified"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{postID} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
@@ -111,14 +185,25 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 	post := getPostFromCtx(r)
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	user := getUserFromContext(r)
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, user.ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 	post.Comments = comments
 
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	if err := app.embedQuotedPost(r.Context(), user, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachPoll(r.Context(), user, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponseWithETag(w, r, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 
 		return
@@ -129,42 +214,55 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // Delete Post by id
 //
 //	@Summary		Delete a post
-//	@Description	Delete a post by post id
+//	@Description	Moves a post to the trash, restorable until the retention window passes
 //	@Tags			post
 //	@Produce		json
-//	@Param			id	path	int	true	"Post ID"
-//	@Success		204	"Post successfully deleted"
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id			path	int		true	"Post ID"
+//	@Param			If-Match	header	string	false	"Post version the write expects, quoted like an ETag"
+//	@Success		204			"Post successfully deleted"
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [delete]
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "postID") //extract postId from url
-
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	post := getPostFromCtx(r)
 
-	if err != nil {
-		app.internalServerError(w, r, err)
+	if !app.checkPostIfMatch(w, r, post) {
 		return
 	}
+
 	ctx := r.Context()
 
-	if err := app.store.Posts.Delete(ctx, id); err != nil {
+	// the store only trashes the post if no write landed since the If-Match
+	// check above
+	if err := app.store.Posts.Delete(ctx, post.ID, post.Version); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrEditConflict):
+			app.ConflictResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 
 		}
 		return
 	}
+
+	app.deliverWebhook(ctx, post.UserID, store.WebhookPostDeleted, map[string]int64{"id": post.ID, "user_id": post.UserID})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=10"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title      *string    `json:"title" validate:"omitempty,max=10"`
+	Content    *string    `json:"content" validate:"omitempty,max=1000"`
+	Tags       *[]string  `json:"tags" validate:"omitempty,max=10"`
+	Visibility *string    `json:"visibility" validate:"omitempty,oneof=public followers unlisted"`
+	Status     *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time `json:"publish_at"`
 }
 
 // Update Post by id
@@ -173,18 +271,28 @@ type UpdatePostPayload struct {
 //	@Description	Update a post by post id
 //	@Tags			post
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			poyload	body		UpdatePostPayload	true	"Post Payload"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
-//	@Failure		401		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				false	"Post version the write expects, quoted like an ETag"
+//	@Param			poyload		body		UpdatePostPayload	true	"Post Payload"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
+	// the version the client last saw is matched against the one just read
+	// here, and the store then only writes if the row is still at it
+	if !app.checkPostIfMatch(w, r, post) {
+		return
+	}
+
 	var payload UpdatePostPayload
 
 	if err := readJSON(w, r, &payload); err != nil {
@@ -197,17 +305,78 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// hashtags are re-extracted from the new content, so the ones removed
+	// from it go away unless they were also set explicitly
+	explicitTags := post.ExplicitTags
+	if payload.Tags != nil {
+		var err error
+		explicitTags, err = tags.NormalizeAll(*payload.Tags)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	// mentions in a post that wasn't published yet were never notified
+	wasPublished := post.Status == store.PostStatusPublished
+	var previousMentions []store.Mention
+	if wasPublished {
+		previousMentions = post.Mentions
+	}
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
+	post.Mentions = parseMentions(post.Content)
+	if payload.Content != nil || payload.Tags != nil {
+		post.Tags = tags.Merge(explicitTags, post.Content)
+		post.ExplicitTags = explicitTags
+	}
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+	if payload.Status != nil {
+		if post.Status == store.PostStatusPublished && *payload.Status != store.PostStatusPublished {
+			app.badRequestResponse(w, r, errors.New("a published post can't go back to draft or scheduled"))
+			return
+		}
+		post.Status = *payload.Status
+		if post.Status != store.PostStatusScheduled {
+			post.PublishAt = nil
+		}
+	}
+	if payload.PublishAt != nil {
+		post.PublishAt = payload.PublishAt
+	}
 
-	if err := app.store.Posts.Update(r.Context(), post); err != nil {
-		app.internalServerError(w, r, err)
+	if err := validatePostStatus(post.Status, post.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrEditConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
+
+	app.notifyPostMentions(ctx, getUserFromContext(r).ID, post, previousMentions)
+	if !wasPublished {
+		app.notifyQuote(ctx, post)
+	}
+	app.deliverWebhook(ctx, post.UserID, store.WebhookPostUpdated, post)
+
+	w.Header().Set("ETag", postETag(post))
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -236,6 +405,16 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		visible, err := app.canViewPost(ctx, getUserFromContext(r), post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
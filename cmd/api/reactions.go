@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"social/internal/store"
+)
+
+type ReactPostPayload struct {
+	Kind string `json:"kind" validate:"required,oneof=like love laugh wow sad angry"`
+}
+
+// publishReactions pushes the new reaction counts of a post to the clients
+// following it. Being shared by every subscriber, they are counted for no
+// viewer in particular, so without Mine and without leaving blocked users out.
+func (app *application) publishReactions(ctx context.Context, postID int64) {
+	reactions, err := app.store.Reactions.Get(ctx, postID, 0)
+	if err != nil {
+		app.logger.Errorw("error counting reactions to publish", "post", postID, "error", err)
+		return
+	}
+
+	app.publish(postTopic(postID), "reaction.updated", reactions)
+}
+
+// ReactPost godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Reacts to a published post, notifying its author. Reacting again replaces the kind of the
+//	@Description	earlier reaction without notifying anyone.
+//	@Tags			post
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Post ID"
+//	@Param			payload	body		ReactPostPayload	true	"Reaction"
+//	@Success		200		{object}	store.Reactions
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reaction [put]
+func (app *application) reactPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	var payload ReactPostPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestResponse(w, r, errors.New("only published posts can be reacted to"))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	created, err := app.store.Reactions.Set(ctx, user.ID, post.ID, payload.Kind)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if created {
+		app.notify(ctx, post.UserID, user.ID, store.NotificationReaction, &post.ID, nil)
+	}
+	app.publishReactions(ctx, post.ID)
+
+	reactions, err := app.store.Reactions.Get(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, reactions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnreactPost godoc
+//
+//	@Summary		Removes a reaction
+//	@Description	Removes the reaction of the authenticated user to a post
+//	@Tags			post
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Reaction removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reaction [delete]
+func (app *application) unreactPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Reactions.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.publishReactions(r.Context(), post.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPostReactions godoc
+//
+//	@Summary		Get the reactions to a post
+//	@Description	Counts the reactions to a post by kind, leaving out users blocked either way, along with
+//	@Description	the reaction of the authenticated user
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Reactions
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [get]
+func (app *application) getPostReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	reactions, err := app.store.Reactions.Get(r.Context(), post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, reactions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
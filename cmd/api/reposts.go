@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"social/internal/store"
+	"time"
+)
+
+// embedQuotedPost sets the original of a quote post when viewer may read it.
+// It is left nil when the original was deleted or hidden from the viewer.
+func (app *application) embedQuotedPost(ctx context.Context, viewer *store.User, post *store.Post) error {
+	if post.QuotedPostID == nil {
+		return nil
+	}
+
+	original, err := app.store.Posts.GetById(ctx, *post.QuotedPostID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	visible, err := app.canViewPost(ctx, viewer, original)
+	if err != nil || !visible || original.Status != store.PostStatusPublished {
+		return err
+	}
+
+	post.QuotedPost = &store.QuotedPost{
+		ID:          original.ID,
+		UserID:      original.UserID,
+		Username:    original.User.UserName,
+		Title:       original.Title,
+		Content:     original.Content,
+		ContentHTML: original.ContentHTML,
+		Tags:        original.Tags,
+	}
+	if createdAt, err := time.Parse(time.RFC3339, original.CreatedAt); err == nil {
+		post.QuotedPost.CreatedAt = createdAt
+	}
+
+	return nil
+}
+
+// notifyQuote tells the author of the post quoted by post about the quote,
+// once post is published. Drafts and scheduled posts notify when they publish.
+func (app *application) notifyQuote(ctx context.Context, post *store.Post) {
+	if post.Status != store.PostStatusPublished || post.QuotedPostID == nil {
+		return
+	}
+
+	var authorID int64
+	if post.QuotedPost != nil {
+		authorID = post.QuotedPost.UserID
+	} else {
+		original, err := app.store.Posts.GetById(ctx, *post.QuotedPostID)
+		if err != nil {
+			if err != store.ErrNotFound {
+				app.logger.Errorw("error fetching quoted post", "post", *post.QuotedPostID, "error", err)
+			}
+			return
+		}
+		authorID = original.UserID
+	}
+
+	app.notify(ctx, authorID, post.UserID, store.NotificationQuote, &post.ID, nil)
+}
+
+// RepostPost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares a post as is with the followers of the authenticated user. Only published public posts
+//	@Description	by public accounts can be reposted; the repost disappears if the original is deleted or hidden.
+//	@Tags			post
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Post reposted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Post already reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [put]
+func (app *application) repostPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Reposts.Create(ctx, user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotShareable:
+			app.badRequestResponse(w, r, err)
+		case store.ErrConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notify(ctx, post.UserID, user.ID, store.NotificationRepost, &post.ID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnrepostPost godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the repost of a post by the authenticated user
+//	@Tags			post
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Repost removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/unrepost [put]
+func (app *application) unrepostPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Reposts.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"social/internal/diff"
+	"social/internal/store"
+	"social/internal/tags"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type revisionDiff struct {
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+func currentRevision(post *store.Post) *store.PostRevision {
+	return &store.PostRevision{
+		PostID:    post.ID,
+		Version:   post.Version,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		CreatedAt: post.UpdatedAt,
+	}
+}
+
+// getRevision returns the post as it was at version, the current version included.
+func (app *application) getRevision(ctx context.Context, post *store.Post, version int) (*store.PostRevision, error) {
+	if version == post.Version {
+		return currentRevision(post), nil
+	}
+	return app.store.Revisions.Get(ctx, post.ID, version)
+}
+
+// Get post revisions godoc
+//
+//	@Summary		Lists post revisions
+//	@Description	Lists every version of a post, newest first, the current one included
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.PostRevision
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	revisions, err := app.store.Revisions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	revisions = append([]store.PostRevision{*currentRevision(post)}, revisions...)
+
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Get post revision godoc
+//
+//	@Summary		Fetches a post revision
+//	@Description	Fetches a post as it was at the given version
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version"
+//	@Success		200		{object}	store.PostRevision
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version} [get]
+func (app *application) getPostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revision, err := app.getRevision(r.Context(), post, version)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revision); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Diff post revisions godoc
+//
+//	@Summary		Diffs two post revisions
+//	@Description	Returns unified diffs of the title and content between two versions of a post
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	true	"Version to diff from"
+//	@Param			to		query		int	false	"Version to diff to, defaults to the current one"
+//	@Success		200		{object}	revisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	qs := r.URL.Query()
+
+	from, err := strconv.Atoi(qs.Get("from"))
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid from version: %w", err))
+		return
+	}
+
+	to := post.Version
+	if qs.Get("to") != "" {
+		to, err = strconv.Atoi(qs.Get("to"))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid to version: %w", err))
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	var revs [2]*store.PostRevision
+	for i, version := range []int{from, to} {
+		revs[i], err = app.getRevision(ctx, post, version)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	fromName, toName := fmt.Sprintf("v%d", from), fmt.Sprintf("v%d", to)
+	d := revisionDiff{
+		From:    from,
+		To:      to,
+		Title:   diff.Unified(fromName, toName, revs[0].Title, revs[1].Title),
+		Content: diff.Unified(fromName, toName, revs[0].Content, revs[1].Content),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, d); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Restore post revision godoc
+//
+//	@Summary		Restores a post revision
+//	@Description	Restores the title, content and tags of an older version as a new version
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			version		path		int		true	"Version to restore"
+//	@Param			If-Match	header		string	false	"Post version the write expects, quoted like an ETag"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/restore [post]
+func (app *application) restorePostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if !app.checkPostIfMatch(w, r, post) {
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	revision, err := app.store.Revisions.Get(ctx, post.ID, version)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Tags = revision.Tags
+	post.ExplicitTags = tags.Explicit(revision.Tags, revision.Content, post.ExplicitTags)
+	var previousMentions []store.Mention
+	if post.Status == store.PostStatusPublished {
+		previousMentions = post.Mentions
+	}
+	post.Mentions = parseMentions(post.Content)
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrEditConflict:
+			app.ConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.notifyPostMentions(ctx, getUserFromContext(r).ID, post, previousMentions)
+	app.deliverWebhook(ctx, post.UserID, store.WebhookPostUpdated, post)
+
+	w.Header().Set("ETag", postETag(post))
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
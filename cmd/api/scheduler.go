@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"social/internal/store"
+	"time"
+)
+
+// runPostScheduler publishes scheduled posts once their publish_at has passed.
+// Every replica runs it; the store claims due rows with row locks so each post
+// is only published once. Followers are reached through the post.published
+// event the store emits, see onPostPublished.
+func (app *application) runPostScheduler(ctx context.Context) {
+	ticker := time.NewTicker(app.config.scheduler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.publishDuePosts(ctx)
+		}
+	}
+}
+
+func (app *application) publishDuePosts(ctx context.Context) {
+	for {
+		ids, err := app.store.Posts.PublishDue(ctx, app.config.scheduler.batchSize)
+		if err != nil {
+			app.logger.Errorw("error publishing scheduled posts", "error", err)
+			return
+		}
+
+		if len(ids) > 0 {
+			app.logger.Infow("published scheduled posts", "count", len(ids))
+		}
+		for _, id := range ids {
+			app.notifyScheduledPost(ctx, id)
+		}
+
+		// a short batch means nothing else is due right now
+		if len(ids) < app.config.scheduler.batchSize {
+			return
+		}
+	}
+}
+
+// notifyScheduledPost sends the notifications a scheduled post held back until
+// it published. It runs here rather than in onPostPublished because only the
+// replica that claimed the post gets its id, so users are notified once.
+func (app *application) notifyScheduledPost(ctx context.Context, id int64) {
+	post, err := app.store.Posts.GetById(ctx, id)
+	if err != nil {
+		app.logger.Errorw("error fetching published post", "post", id, "error", err)
+		return
+	}
+
+	app.notifyPostMentions(ctx, post.UserID, post, nil)
+	app.notifyQuote(ctx, post)
+}
+
+// runTrashPurger hard deletes posts and comments that stayed in the trash
+// longer than the retention window, along with old webhook delivery logs and
+// uploads that were never attached or belonged to purged posts.
+func (app *application) runTrashPurger(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeTrash(ctx)
+			app.purgeWebhookDeliveries(ctx)
+			app.purgeMedia(ctx)
+		}
+	}
+}
+
+func (app *application) purgeTrash(ctx context.Context) {
+	before := time.Now().Add(-app.config.trash.retention)
+
+	comments, err := app.store.Comments.Purge(ctx, before)
+	if err != nil {
+		app.logger.Errorw("error purging trashed comments", "error", err)
+		return
+	}
+
+	posts, err := app.store.Posts.Purge(ctx, before)
+	if err != nil {
+		app.logger.Errorw("error purging trashed posts", "error", err)
+		return
+	}
+
+	if posts > 0 || comments > 0 {
+		app.logger.Infow("purged trash", "posts", posts, "comments", comments)
+	}
+}
+
+// runTrendingAggregator refreshes the trending snapshots of every period, so
+// the trending endpoints only read precomputed rows.
+func (app *application) runTrendingAggregator(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trending.interval)
+	defer ticker.Stop()
+
+	app.refreshTrending(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.refreshTrending(ctx)
+		}
+	}
+}
+
+func (app *application) refreshTrending(ctx context.Context) {
+	for period := range store.TrendingPeriods {
+		if err := app.store.Trending.Refresh(ctx, period, app.config.trending.size); err != nil {
+			app.logger.Errorw("error refreshing trending", "period", period, "error", err)
+		}
+	}
+}
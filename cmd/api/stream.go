@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"social/internal/events"
+	"social/internal/store"
+	"social/internal/stream"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStreamPosts caps how many posts a single stream can follow comments on.
+const maxStreamPosts = 20
+
+func userTopic(userID int64) string { return fmt.Sprintf("user:%d", userID) }
+func postTopic(postID int64) string { return fmt.Sprintf("post:%d", postID) }
+
+// publish sends an event to the clients streaming topic on every replica,
+// relaying it through the events listener. Events too large for a Postgres
+// notification only reach the clients of this replica. Like notifications,
+// failures are logged rather than failing the request.
+func (app *application) publish(topic, eventType string, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		app.logger.Errorw("error encoding event", "topic", topic, "type", eventType, "error", err)
+		return
+	}
+
+	ev := events.StreamEvent{Topic: topic, Type: eventType, Data: raw}
+	if err := events.Publish(context.Background(), app.events, events.Streamed, ev); err != nil {
+		app.logger.Warnw("error relaying event, publishing it locally", "topic", topic, "type", eventType, "error", err)
+		app.publishLocal(topic, eventType, raw)
+	}
+}
+
+// publishLocal sends an event to the clients streaming topic on this replica
+// only, for events every replica publishes by itself.
+func (app *application) publishLocal(topic, eventType string, data any) {
+	if err := app.hub.Publish(topic, eventType, data); err != nil {
+		app.logger.Errorw("error publishing event", "topic", topic, "type", eventType, "error", err)
+	}
+}
+
+// publishPost pushes a newly published post to the streams of the author and
+// of the followers whose feed would show it, leaving out those who muted the
+// author or filter the post out. Every replica runs it from onPostPublished,
+// so it publishes locally.
+func (app *application) publishPost(ctx context.Context, post *store.Post) {
+	if post.Status != store.PostStatusPublished {
+		return
+	}
+
+	followers, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("error fetching followers to publish post", "post", post.ID, "error", err)
+		return
+	}
+
+	viewers, err := app.store.Posts.FilterFeedViewers(ctx, post.ID, followers)
+	if err != nil {
+		app.logger.Errorw("error filtering followers to publish post", "post", post.ID, "error", err)
+		return
+	}
+
+	for _, id := range append(viewers, post.UserID) {
+		app.publishLocal(userTopic(id), "post.created", post)
+	}
+}
+
+// commentEvents are the events published on post topics about a comment by
+// its author, which subscribers with a block with the author don't get.
+var commentEvents = map[string]bool{
+	"comment.created":  true,
+	"comment.updated":  true,
+	"comment.restored": true,
+}
+
+// deliverable reports whether e may be sent to viewer. Post topics are shared
+// by every subscriber, so blocks are checked here for each of them, like
+// the comment listing does.
+func (app *application) deliverable(ctx context.Context, viewer *store.User, e stream.Event) bool {
+	if !commentEvents[e.Type] {
+		return true
+	}
+
+	var comment struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := json.Unmarshal(e.Data, &comment); err != nil {
+		return false
+	}
+
+	return !app.blockedWith(ctx, viewer, comment.UserID)
+}
+
+// blockedWith reports whether viewer and userID have a block between them.
+// Lookup errors count as a block, so a push is dropped rather than leaked.
+func (app *application) blockedWith(ctx context.Context, viewer *store.User, userID int64) bool {
+	if userID == viewer.ID {
+		return false
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, userID)
+	if err != nil {
+		app.logger.Errorw("error checking block for live push", "user", userID, "error", err)
+		return true
+	}
+
+	return blocked
+}
+
+// stream godoc
+//
+//	@Summary		Streams real-time events
+//	@Description	Server-sent events stream of new feed posts and notifications of the authenticated user,
+//	@Description	and of the comment activity on the given posts. Events carry an id to resume from with
+//	@Description	the Last-Event-ID header; a resync event means some were missed and should be refetched.
+//	@Description	Event ids are kept by each API replica, so resuming only works against the same replica
+//	@Description	and a reconnection landing on another one gets a resync.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			posts			query		string	false	"Comma separated IDs of posts to follow comments on"
+//	@Param			Last-Event-ID	header		string	false	"ID of the last event received"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	topics := []string{userTopic(user.ID)}
+
+	if param := r.URL.Query().Get("posts"); param != "" {
+		ids := strings.Split(param, ",")
+		if len(ids) > maxStreamPosts {
+			app.badRequestResponse(w, r, fmt.Errorf("at most %d posts can be followed", maxStreamPosts))
+			return
+		}
+
+		for _, param := range ids {
+			id, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64)
+			if err != nil {
+				app.badRequestResponse(w, r, err)
+				return
+			}
+
+			post, err := app.store.Posts.GetById(ctx, id)
+			if err == nil {
+				var ok bool
+				ok, err = app.canViewPost(ctx, user, post)
+				if err == nil && !ok {
+					err = store.ErrNotFound
+				}
+			}
+			if err != nil {
+				switch err {
+				case store.ErrNotFound:
+					app.notFoundResponse(w, r, err)
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+
+			topics = append(topics, postTopic(id))
+		}
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var since uint64
+	if lastID != "" {
+		var err error
+		since, err = strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the server write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	sub, resumed := app.hub.Subscribe(topics, since)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumed {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+
+		case e, ok := <-sub.C:
+			if !ok {
+				// the client fell behind, it resumes from the last event it got
+				if errors.Is(sub.Err(), stream.ErrOverflow) {
+					fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+					rc.Flush()
+				}
+				return
+			}
+			if !app.deliverable(ctx, user, e) {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
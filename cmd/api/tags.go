@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"social/internal/tags"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// get tag posts godoc
+//
+//	@Summary		Get posts by tag
+//	@Description	Retrieves the published posts carrying a tag that the authenticated user is allowed to see
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag, with or without the leading #"
+//	@Param			limit	query		int		false	"Number of posts to return"	default(20)
+//	@Param			offset	query		int		false	"Number of posts to skip"	default(0)
+//	@Param			sort	query		string	false	"Sort order: asc or desc"	default(desc)	Enum(asc, desc)
+//	@Param			search	query		string	false	"Search in title and content"
+//	@Param			since	query		string	false	"Posts created at or after, YYYY-MM-DD HH:MM:SS"
+//	@Param			until	query		string	false	"Posts created at or before, YYYY-MM-DD HH:MM:SS"
+//	@Success		200		{object}	[]store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tags.Normalize(chi.URLParam(r, "tag"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, err := app.store.Posts.GetByTag(r.Context(), tag, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// search tags godoc
+//
+//	@Summary		Autocomplete tags
+//	@Description	Lists the tags starting with a prefix, most used first
+//	@Tags			tags
+//	@Produce		json
+//	@Param			prefix	query		string	true	"Tag prefix, with or without the leading #"
+//	@Param			limit	query		int		false	"Number of tags to return"	default(10)
+//	@Success		200		{object}	[]store.Tag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags [get]
+func (app *application) searchTagsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	prefix, err := tags.NormalizePrefix(qs.Get("prefix"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	limit := 10
+	if l := qs.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 20 {
+			app.badRequestResponse(w, r, errors.New("limit must be between 1 and 20"))
+			return
+		}
+	}
+
+	found, err := app.store.Tags.Search(r.Context(), prefix, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, found); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// inRetention reports whether something deleted at deletedAt can still be restored.
+func (app *application) inRetention(deletedAt *time.Time) bool {
+	return deletedAt != nil && time.Since(*deletedAt) < app.config.trash.retention
+}
+
+// Get trash godoc
+//
+//	@Summary		Get my trash
+//	@Description	Retrieves the deleted posts of the authenticated user that can still be restored
+//	@Tags			feed
+//	@Produce		json
+//	@Param			limit	query		int	false	"Number of posts to return"	default(20)
+//	@Param			offset	query		int	false	"Number of posts to skip"	default(0)
+//	@Success		200		{object}	[]store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/trash [get]
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	since := time.Now().Add(-app.config.trash.retention)
+
+	posts, err := app.store.Posts.GetTrash(r.Context(), user.ID, since, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Restore post godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Restores a post from the trash within the retention window
+//	@Tags			post
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	post, err := app.store.Posts.GetTrashedById(ctx, id)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.isOwnerOrRole(ctx, getUserFromContext(r), post.UserID, "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// someone else's trash is reported missing, not forbidden, so it doesn't
+	// reveal the post exists
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if !app.inRetention(post.DeletedAt) {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Posts.Restore(ctx, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	post.DeletedAt = nil
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
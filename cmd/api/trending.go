@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"social/internal/store"
+	"strconv"
+)
+
+// parseTrendingQuery reads the period and limit of the trending endpoints,
+// a day and 20 entries by default.
+func parseTrendingQuery(r *http.Request) (string, int, error) {
+	qs := r.URL.Query()
+
+	period := qs.Get("period")
+	if period == "" {
+		period = "day"
+	}
+	if _, ok := store.TrendingPeriods[period]; !ok {
+		return "", 0, errors.New("period must be one of hour, day or week")
+	}
+
+	limit := 20
+	if l := qs.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 50 {
+			return "", 0, errors.New("limit must be between 1 and 50")
+		}
+	}
+
+	return period, limit, nil
+}
+
+// get trending tags godoc
+//
+//	@Summary		Get trending tags
+//	@Description	Retrieves the tags trending over the period, from the last snapshot of the aggregator
+//	@Tags			explore
+//	@Produce		json
+//	@Param			period	query		string	false	"Sliding window"			default(day)	Enum(hour, day, week)
+//	@Param			limit	query		int		false	"Number of tags to return"	default(20)
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trending/tags [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	period, limit, err := parseTrendingQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	trending, err := app.store.Trending.GetTags(r.Context(), period, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, trending); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// get trending posts godoc
+//
+//	@Summary		Get trending posts
+//	@Description	Retrieves the posts trending over the period that the authenticated user is allowed to see
+//	@Tags			explore
+//	@Produce		json
+//	@Param			period	query		string	false	"Sliding window"			default(day)	Enum(hour, day, week)
+//	@Param			limit	query		int		false	"Number of posts to return"	default(20)
+//	@Success		200		{object}	[]store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/trending/posts [get]
+func (app *application) getTrendingPostsHandler(w http.ResponseWriter, r *http.Request) {
+	period, limit, err := parseTrendingQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	trending, err := app.store.Trending.GetPosts(r.Context(), period, user.ID, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, trending); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"social/internal/store"
 	"strconv"
@@ -37,14 +38,17 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Fetches a user profile with ID
+//	@Description	Follows a user by ID, or files a follow request if the account is private
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
 //	@Success		204		{string}	string	"User followed"
-//	@Failure		404		{object}	error	"User payload missing"
-//	@Failure		400		{object}	error	"User not found"
+//	@Success		202		{string}	string	"Follow request sent"
+//	@Failure		400		{object}	error	"User payload missing"
+//	@Failure		404		{object}	error	"User not found"
+//	@Failure		403		{object}	error	"Blocked"
+//	@Failure		409		{object}	error	"Already following or requested"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -53,16 +57,76 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 	followedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if followedID == followerUser.ID {
+		app.badRequestResponse(w, r, errors.New("you can't follow yourself"))
+		return
 	}
 
 	ctx := r.Context()
 
+	followedUser, err := app.store.Users.GetById(ctx, followedID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, followerUser.ID, followedID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if followedUser.IsPrivate {
+		following, err := app.store.Followers.IsFollowing(ctx, followerUser.ID, followedID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if following {
+			app.ConflictResponse(w, r, store.ErrConflict)
+			return
+		}
+
+		if err := app.store.FollowRequests.Create(ctx, followerUser.ID, followedID); err != nil {
+			switch err {
+			case store.ErrConflict:
+				app.ConflictResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		app.notify(ctx, followedID, followerUser.ID, store.NotificationFollowRequest, nil, nil)
+
+		if err := app.jsonResponse(w, http.StatusAccepted, map[string]string{"status": store.FollowRequestPending}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedID); err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.ConflictResponse(w, r, err)
 			return
 
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r)
+			return
+
 		default:
 			app.internalServerError(w, r, err)
 			return
@@ -70,6 +134,9 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 	}
 
+	app.notify(ctx, followedID, followerUser.ID, store.NotificationFollow, nil, nil)
+	app.deliverWebhook(ctx, followedID, store.WebhookFollow, followWebhookPayload(followerUser.ID, followedID))
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -93,6 +160,7 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	unfollowedID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	ctx := r.Context()
@@ -103,6 +171,12 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 
 	}
 
+	// also withdraws a pending request to a private account
+	if err := app.store.FollowRequests.Delete(ctx, unfollowedID, followerUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -139,6 +213,48 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type UpdatePrivacyPayload struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// UpdatePrivacy godoc
+//
+//	@Summary		Updates account privacy
+//	@Description	Makes the authenticated user's account private or public
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdatePrivacyPayload	true	"Privacy setting"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/privacy [put]
+func (app *application) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload UpdatePrivacyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.SetPrivacy(r.Context(), user.ID, *payload.IsPrivate); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	user.IsPrivate = *payload.IsPrivate
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"social/internal/store"
+)
+
+// canViewPost reports whether viewer is allowed to see post, mirroring the
+// store's SQL visibility rules. Callers respond with 404 rather than 403 so
+// hidden posts can't be told apart from missing ones.
+func (app *application) canViewPost(ctx context.Context, viewer *store.User, post *store.Post) (bool, error) {
+	if viewer.ID == post.UserID {
+		return true, nil
+	}
+
+	// drafts and scheduled posts are only visible to their author
+	if post.Status != store.PostStatusPublished {
+		return false, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, post.UserID)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		return false, nil
+	}
+
+	// unlisted posts are left out of discovery, but anyone with the link can read them
+	if post.Visibility != store.PostVisibilityFollowers && !post.User.IsPrivate {
+		return true, nil
+	}
+
+	return app.store.Followers.IsFollowing(ctx, viewer.ID, post.UserID)
+}
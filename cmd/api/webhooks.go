@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"social/internal/store"
+	"social/internal/webhooks"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type webhookKey string
+
+const webhookCtx webhookKey = "webhook"
+
+type CreateWebhookPayload struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,max=5,dive,oneof=post.created post.updated post.deleted comment.created follow"`
+}
+
+type UpdateWebhookPayload struct {
+	URL    *string   `json:"url" validate:"omitempty,url,max=2048"`
+	Events *[]string `json:"events" validate:"omitempty,min=1,max=5,dive,oneof=post.created post.updated post.deleted comment.created follow"`
+	Active *bool     `json:"active"`
+}
+
+// webhookEnvelope is the body POSTed to receivers.
+type webhookEnvelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func followWebhookPayload(followerID, userID int64) map[string]int64 {
+	return map[string]int64{"follower_id": followerID, "user_id": userID}
+}
+
+// validateWebhookURL checks raw is an http or https URL whose host resolves to
+// public addresses only, see webhooks.CheckHost.
+func validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("webhook url must be http or https")
+	}
+	if u.Hostname() == "" {
+		return errors.New("webhook url must have a host")
+	}
+	return webhooks.CheckHost(ctx, u.Hostname())
+}
+
+// deliverWebhook queues data for the webhooks of userID subscribed to
+// eventType. Failures are logged rather than failing the request.
+func (app *application) deliverWebhook(ctx context.Context, userID int64, eventType string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		app.logger.Errorw("error encoding webhook payload", "type", eventType, "error", err)
+		return
+	}
+
+	if _, err := app.store.Webhooks.Enqueue(ctx, userID, eventType, payload); err != nil {
+		app.logger.Errorw("error queueing webhook deliveries", "type", eventType, "user", userID, "error", err)
+	}
+}
+
+// runWebhookDispatcher sends the queued deliveries. Every replica runs it;
+// deliveries are claimed with a lease so each attempt is made once.
+func (app *application) runWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(app.config.webhooks.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.dispatchWebhooks(ctx)
+		}
+	}
+}
+
+func (app *application) dispatchWebhooks(ctx context.Context) {
+	// the lease outlives the request timeout, so a delivery is never sent twice at once
+	lease := app.config.webhooks.timeout + 30*time.Second
+
+	for {
+		due, err := app.store.Webhooks.ClaimDue(ctx, app.config.webhooks.batchSize, lease)
+		if err != nil {
+			app.logger.Errorw("error claiming webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := range due {
+			wg.Add(1)
+			go func(d *store.DueDelivery) {
+				defer wg.Done()
+				app.sendWebhook(ctx, d)
+			}(&due[i])
+		}
+		wg.Wait()
+
+		// a short batch means nothing else is due right now
+		if len(due) < app.config.webhooks.batchSize {
+			return
+		}
+	}
+}
+
+func (app *application) sendWebhook(ctx context.Context, d *store.DueDelivery) {
+	body, err := json.Marshal(webhookEnvelope{ID: d.ID, Type: d.EventType, CreatedAt: d.CreatedAt, Data: d.Payload})
+	if err != nil {
+		app.logger.Errorw("error encoding webhook delivery", "delivery", d.ID, "error", err)
+		return
+	}
+
+	status, err := app.webhookSender.Send(ctx, webhooks.Request{
+		DeliveryID: d.ID,
+		URL:        d.URL,
+		Secret:     d.Secret,
+		EventType:  d.EventType,
+		Body:       body,
+	})
+	if err == nil {
+		if err := app.store.Webhooks.RecordSuccess(ctx, d, status); err != nil {
+			app.logger.Errorw("error recording webhook delivery", "delivery", d.ID, "error", err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if d.Attempts < app.config.webhooks.maxAttempts {
+		t := time.Now().Add(webhooks.Backoff(d.Attempts))
+		retryAt = &t
+	}
+
+	disabled, recErr := app.store.Webhooks.RecordFailure(ctx, d, status, err.Error(), retryAt, app.config.webhooks.maxFailures)
+	if recErr != nil {
+		app.logger.Errorw("error recording webhook delivery", "delivery", d.ID, "error", recErr)
+		return
+	}
+
+	app.logger.Warnw("webhook delivery failed", "delivery", d.ID, "webhook", d.WebhookID, "attempt", d.Attempts, "error", err)
+	if disabled {
+		app.logger.Warnw("webhook disabled after repeated failures", "webhook", d.WebhookID)
+	}
+}
+
+func (app *application) purgeWebhookDeliveries(ctx context.Context) {
+	before := time.Now().Add(-app.config.webhooks.retention)
+
+	n, err := app.store.Webhooks.PurgeDeliveries(ctx, before)
+	if err != nil {
+		app.logger.Errorw("error purging webhook deliveries", "error", err)
+		return
+	}
+
+	if n > 0 {
+		app.logger.Infow("purged webhook deliveries", "deliveries", n)
+	}
+}
+
+// GetWebhooks godoc
+//
+//	@Summary		Lists webhooks
+//	@Description	Lists the webhooks of the authenticated user. Secrets are not returned.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Success		200	{object}	[]store.Webhook
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/webhooks [get]
+func (app *application) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	hooks, err := app.store.Webhooks.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, hooks); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateWebhook godoc
+//
+//	@Summary		Creates a webhook
+//	@Description	Subscribes a URL to events of the authenticated user's account. Deliveries are signed with
+//	@Description	HMAC-SHA256 over "<timestamp>.<body>" using the returned secret, which is only shown once.
+//	@Description	Events: post.created, post.updated, post.deleted, comment.created, follow.
+//	@Description	The URL must resolve to public addresses; loopback, private and link-local ones are refused.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateWebhookPayload	true	"Webhook"
+//	@Success		201		{object}	store.Webhook
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/webhooks [post]
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validateWebhookURL(r.Context(), payload.URL); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	hook := &store.Webhook{
+		UserID: user.ID,
+		URL:    payload.URL,
+		Secret: secret,
+		Events: payload.Events,
+	}
+
+	if err := app.store.Webhooks.Create(r.Context(), hook); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, hook); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetWebhook godoc
+//
+//	@Summary		Fetches a webhook
+//	@Description	Fetches a webhook of the authenticated user
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookID	path		int	true	"Webhook ID"
+//	@Success		200			{object}	store.Webhook
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/webhooks/{webhookID} [get]
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getWebhookFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateWebhook godoc
+//
+//	@Summary		Updates a webhook
+//	@Description	Updates the URL or events of a webhook, or turns it on and off. Turning a webhook that was
+//	@Description	disabled after repeated failures back on resets its failure count.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int						true	"Webhook ID"
+//	@Param			payload		body		UpdateWebhookPayload	true	"Webhook"
+//	@Success		200			{object}	store.Webhook
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/webhooks/{webhookID} [patch]
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	var payload UpdateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.URL != nil {
+		if err := validateWebhookURL(r.Context(), *payload.URL); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		hook.URL = *payload.URL
+	}
+	if payload.Events != nil {
+		hook.Events = *payload.Events
+	}
+	if payload.Active != nil {
+		hook.Active = *payload.Active
+	}
+
+	if err := app.store.Webhooks.Update(r.Context(), hook); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, hook); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteWebhook godoc
+//
+//	@Summary		Deletes a webhook
+//	@Description	Deletes a webhook of the authenticated user along with its delivery log
+//	@Tags			webhooks
+//	@Param			webhookID	path	int	true	"Webhook ID"
+//	@Success		204			"Webhook deleted"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/webhooks/{webhookID} [delete]
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	if err := app.store.Webhooks.Delete(r.Context(), hook.ID, hook.UserID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+//
+//	@Summary		Lists webhook deliveries
+//	@Description	Lists the delivery log of a webhook, latest first, with the status, attempts and last
+//	@Description	response of each delivery
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookID	path		int	true	"Webhook ID"
+//	@Param			limit		query		int	false	"Number of deliveries to return"	default(20)
+//	@Param			offset		query		int	false	"Number of deliveries to skip"		default(0)
+//	@Success		200			{object}	[]store.WebhookDelivery
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/webhooks/{webhookID}/deliveries [get]
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	deliveries, err := app.store.Webhooks.GetDeliveries(r.Context(), hook.ID, fq.Limit, fq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, deliveries); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) webhookContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		hook, err := app.store.Webhooks.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, webhookCtx, hook)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getWebhookFromCtx(r *http.Request) *store.Webhook {
+	hook, _ := r.Context().Value(webhookCtx).(*store.Webhook)
+	return hook
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"social/internal/store"
+	"social/internal/stream"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// typingInterval is how often a connection may announce typing on a post.
+const typingInterval = 3 * time.Second
+
+func typingTopic(postID int64) string { return fmt.Sprintf("typing:%d", postID) }
+
+// wsMessage is every message exchanged over the gateway. Clients send
+// subscribe, unsubscribe, typing and ping; the server answers with
+// subscribed, unsubscribed, pong and error, and pushes the comment events,
+// reaction counts and typing presence of the posts subscribed to.
+type wsMessage struct {
+	Type   string          `json:"type"`
+	PostID int64           `json:"post_id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type wsTyping struct {
+	PostID int64      `json:"post_id"`
+	User   store.User `json:"user"`
+}
+
+// wsConnLimiter caps the gateway connections open per user.
+type wsConnLimiter struct {
+	mu    sync.Mutex
+	conns map[int64]int
+}
+
+func (l *wsConnLimiter) acquire(userID int64, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conns == nil {
+		l.conns = map[int64]int{}
+	}
+	if l.conns[userID] >= max {
+		return false
+	}
+	l.conns[userID]++
+	return true
+}
+
+func (l *wsConnLimiter) release(userID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.conns[userID]--
+	if l.conns[userID] <= 0 {
+		delete(l.conns, userID)
+	}
+}
+
+// wsClient is one gateway connection. Only the write loop writes to conn;
+// everything else queues messages on out.
+type wsClient struct {
+	app    *application
+	conn   *websocket.Conn
+	user   *store.User
+	out    chan wsMessage
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+	subs   map[int64]*stream.Subscription
+	typing map[int64]time.Time
+}
+
+// websocket godoc
+//
+//	@Summary		Opens the live discussion gateway
+//	@Description	WebSocket gateway pushing the comment activity, reaction counts and typing presence of
+//	@Description	subscribed posts. The JWT goes in the Authorization header or, for browsers which can't
+//	@Description	set it, as the subprotocol following bearer, like new WebSocket(url, ["bearer", jwt]).
+//	@Description	Only bearer is echoed back. Clients must send something, a pong at least, every ping
+//	@Description	interval to stay connected.
+//	@Tags			stream
+//	@Param			Sec-WebSocket-Protocol	header	string	false	"bearer, then the JWT"
+//	@Success		101						"Switching protocols"
+//	@Failure		401						{object}	error
+//	@Failure		429						{object}	error
+//	@Router			/ws [get]
+func (app *application) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	token := wsToken(r)
+	if token == "" {
+		app.unauthorizedErrorResponse(w, r, errors.New("token is missing"))
+		return
+	}
+
+	user, err := app.userFromToken(r.Context(), token)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.wsConns.acquire(user.ID, app.config.ws.maxConnsPerUser) {
+		app.tooManyRequestsResponse(w, r, fmt.Errorf("at most %d connections per user", app.config.ws.maxConnsPerUser))
+		return
+	}
+	defer app.wsConns.release(user.ID)
+
+	handshake := func(config *websocket.Config, r *http.Request) error {
+		// the token isn't a subprotocol, only the bearer marker is accepted
+		accepted := config.Protocol
+		config.Protocol = nil
+		for _, p := range accepted {
+			if p == wsBearerProtocol {
+				config.Protocol = []string{wsBearerProtocol}
+			}
+		}
+		return nil
+	}
+
+	timeout := 2 * app.config.ws.pingInterval
+
+	websocket.Server{Handshake: handshake, Handler: func(conn *websocket.Conn) {
+		c := &wsClient{
+			app:    app,
+			conn:   conn,
+			user:   user,
+			out:    make(chan wsMessage, app.config.stream.buffer),
+			done:   make(chan struct{}),
+			subs:   map[int64]*stream.Subscription{},
+			typing: map[int64]time.Time{},
+		}
+		c.run()
+	}}.ServeHTTP(wsHijacker{ResponseWriter: w, timeout: timeout}, r)
+}
+
+// wsBearerProtocol is the subprotocol browsers offer before their JWT.
+const wsBearerProtocol = "bearer"
+
+// wsToken returns the JWT of a gateway request, read from the Authorization
+// header or from the subprotocol following bearer. It is never taken from the
+// query string, which ends up in request logs.
+func wsToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+
+	protocols := strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",")
+	for i, p := range protocols {
+		if strings.TrimSpace(p) == wsBearerProtocol && i+1 < len(protocols) {
+			return strings.TrimSpace(protocols[i+1])
+		}
+	}
+
+	return ""
+}
+
+// wsHijacker hands the gateway a wsLivenessConn instead of the raw hijacked
+// connection.
+type wsHijacker struct {
+	http.ResponseWriter
+	timeout time.Duration
+}
+
+func (h wsHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("websocket: connection can't be hijacked")
+	}
+
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lc := &wsLivenessConn{Conn: conn, timeout: h.timeout}
+	if err := lc.SetReadDeadline(time.Now().Add(h.timeout)); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	// what the server already read ahead comes first
+	buffered, err := buf.Reader.Peek(buf.Reader.Buffered())
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	r := io.MultiReader(bytes.NewReader(buffered), lc)
+
+	return lc, bufio.NewReadWriter(bufio.NewReader(r), buf.Writer), nil
+}
+
+// wsLivenessConn pushes back its read deadline whenever the client sends
+// anything. x/net/websocket answers pings and drops pongs without surfacing
+// them, so this is how a pong answering the server's ping keeps the
+// connection alive.
+type wsLivenessConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *wsLivenessConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (c *wsClient) run() {
+	defer c.close()
+
+	// the hijacked connection keeps the deadlines of the HTTP server
+	if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
+		return
+	}
+
+	go c.writeLoop()
+
+	// the read deadline is pushed back by wsLivenessConn
+	for {
+		var msg wsMessage
+		if err := websocket.JSON.Receive(c.conn, &msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.send(wsMessage{Type: "error", Error: "malformed message"})
+				continue
+			}
+			return
+		}
+
+		c.handle(msg)
+	}
+}
+
+func (c *wsClient) handle(msg wsMessage) {
+	switch msg.Type {
+	case "ping":
+		c.send(wsMessage{Type: "pong"})
+	case "subscribe":
+		c.subscribe(msg.PostID)
+	case "unsubscribe":
+		c.unsubscribe(msg.PostID)
+	case "typing":
+		c.announceTyping(msg.PostID)
+	default:
+		c.send(wsMessage{Type: "error", Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+func (c *wsClient) subscribe(postID int64) {
+	c.mu.Lock()
+	_, subscribed := c.subs[postID]
+	count := len(c.subs)
+	c.mu.Unlock()
+
+	if subscribed {
+		c.send(wsMessage{Type: "subscribed", PostID: postID})
+		return
+	}
+	if count >= maxStreamPosts {
+		c.send(wsMessage{Type: "error", PostID: postID, Error: fmt.Sprintf("at most %d posts can be subscribed to", maxStreamPosts)})
+		return
+	}
+
+	ctx := c.conn.Request().Context()
+
+	post, err := c.app.store.Posts.GetById(ctx, postID)
+	if err == nil {
+		var ok bool
+		ok, err = c.app.canViewPost(ctx, c.user, post)
+		if err == nil && !ok {
+			err = store.ErrNotFound
+		}
+	}
+	if err != nil {
+		if err != store.ErrNotFound {
+			c.app.logger.Errorw("error subscribing to post", "post", postID, "error", err)
+		}
+		c.send(wsMessage{Type: "error", PostID: postID, Error: "post not found"})
+		return
+	}
+
+	sub, _ := c.app.hub.Subscribe([]string{postTopic(postID), typingTopic(postID)}, 0)
+
+	// close may have run meanwhile, and won't see this subscription
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		sub.Close()
+		return
+	default:
+	}
+	c.subs[postID] = sub
+	c.mu.Unlock()
+
+	go c.forward(postID, sub)
+	c.send(wsMessage{Type: "subscribed", PostID: postID})
+}
+
+func (c *wsClient) unsubscribe(postID int64) {
+	c.mu.Lock()
+	sub, ok := c.subs[postID]
+	delete(c.subs, postID)
+	c.mu.Unlock()
+
+	if ok {
+		sub.Close()
+	}
+	c.send(wsMessage{Type: "unsubscribed", PostID: postID})
+}
+
+// forward relays the events of a post subscription until it is closed,
+// leaving out the comments and typing of users with a block with the user.
+func (c *wsClient) forward(postID int64, sub *stream.Subscription) {
+	ctx := c.conn.Request().Context()
+
+	for e := range sub.C {
+		if e.Type == "typing" {
+			var t wsTyping
+			if err := json.Unmarshal(e.Data, &t); err != nil || t.User.ID == c.user.ID {
+				continue
+			}
+			if c.app.blockedWith(ctx, c.user, t.User.ID) {
+				continue
+			}
+		}
+		if !c.app.deliverable(ctx, c.user, e) {
+			continue
+		}
+		c.send(wsMessage{Type: e.Type, PostID: postID, Data: e.Data})
+	}
+
+	if errors.Is(sub.Err(), stream.ErrOverflow) {
+		c.mu.Lock()
+		delete(c.subs, postID)
+		c.mu.Unlock()
+		c.send(wsMessage{Type: "error", PostID: postID, Error: "fell behind, subscribe again and refetch the comments"})
+	}
+}
+
+// announceTyping tells the other subscribers of a post that the user is
+// typing. Presence is ephemeral: nothing is stored and clients drop it after
+// a few seconds without a new announcement.
+func (c *wsClient) announceTyping(postID int64) {
+	c.mu.Lock()
+	_, subscribed := c.subs[postID]
+	throttled := time.Since(c.typing[postID]) < typingInterval
+	if subscribed && !throttled {
+		c.typing[postID] = time.Now()
+	}
+	c.mu.Unlock()
+
+	if !subscribed {
+		c.send(wsMessage{Type: "error", PostID: postID, Error: "not subscribed to this post"})
+		return
+	}
+	if throttled {
+		return
+	}
+
+	c.app.publish(typingTopic(postID), "typing", wsTyping{
+		PostID: postID,
+		User:   store.User{ID: c.user.ID, UserName: c.user.UserName},
+	})
+}
+
+// send queues msg for the write loop, dropping the connection when the
+// client doesn't read fast enough to keep the queue from filling up.
+func (c *wsClient) send(msg wsMessage) {
+	select {
+	case <-c.done:
+	case c.out <- msg:
+	default:
+		c.close()
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(c.app.config.ws.pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+
+		case msg := <-c.out:
+			if err := websocket.JSON.Send(c.conn, msg); err != nil {
+				c.close()
+				return
+			}
+
+		case <-ping.C:
+			c.conn.PayloadType = websocket.PingFrame
+			_, err := c.conn.Write(nil)
+			c.conn.PayloadType = websocket.TextFrame
+			if err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsClient) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, sub := range c.subs {
+			sub.Close()
+		}
+	})
+}
@@ -0,0 +1,17 @@
+ALTER TABLE users
+ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS follow_requests (
+    user_id      bigint NOT NULL,
+    follower_id  bigint NOT NULL,
+    status       varchar(20) NOT NULL DEFAULT 'pending',
+    created_at   timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at   timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, follower_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+    CHECK (status IN ('pending', 'accepted', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id_status ON follow_requests (user_id, status);
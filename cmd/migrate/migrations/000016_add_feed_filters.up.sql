@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS feed_filters (
+    id          bigserial PRIMARY KEY,
+    user_id     bigint NOT NULL,
+    kind        varchar(20) NOT NULL,
+    value       varchar(100) NOT NULL,
+    whole_word  BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at  timestamp(0) with time zone,
+    created_at  timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, kind, value),
+    CHECK (kind IN ('keyword', 'tag'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_feed_filters_user_id ON feed_filters (user_id);
@@ -0,0 +1,10 @@
+ALTER TABLE posts
+ADD COLUMN status varchar(20) NOT NULL DEFAULT 'published';
+
+ALTER TABLE posts
+ADD COLUMN publish_at timestamp(0) with time zone;
+
+ALTER TABLE posts
+ADD CONSTRAINT posts_status_check CHECK (status IN ('draft', 'scheduled', 'published'));
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_publish_at ON posts (publish_at) WHERE status = 'scheduled';
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id     bigint NOT NULL,
+    version     int NOT NULL,
+    title       text NOT NULL,
+    content     text NOT NULL,
+    tags        VARCHAR(100)[],
+    created_at  timestamp(0) with time zone NOT NULL,
+
+    PRIMARY KEY (post_id, version),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_post_id;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
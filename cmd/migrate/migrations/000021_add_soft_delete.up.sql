@@ -0,0 +1,14 @@
+ALTER TABLE posts
+ADD COLUMN deleted_at timestamp(0) with time zone;
+
+ALTER TABLE comments
+ADD COLUMN deleted_at timestamp(0) with time zone;
+
+-- comments left behind by posts deleted before the foreign key existed
+DELETE FROM comments WHERE post_id NOT IN (SELECT id FROM posts);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post_id FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- the original spelling of tags isn't kept, there is nothing to revert
+SELECT 1;
@@ -0,0 +1,10 @@
+-- tags used to be stored as sent by clients, fold them the way the API now
+-- does so tag pages find older posts too
+UPDATE posts SET tags = ARRAY(
+    SELECT lower(ltrim(t.tag, '#'))
+    FROM unnest(posts.tags) WITH ORDINALITY AS t(tag, ord)
+    WHERE ltrim(t.tag, '#') <> ''
+    GROUP BY lower(ltrim(t.tag, '#'))
+    ORDER BY min(t.ord)
+)
+WHERE tags IS NOT NULL;
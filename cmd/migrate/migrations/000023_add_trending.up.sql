@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS trending_tags (
+    period varchar(10) NOT NULL,
+    tag varchar(100) NOT NULL,
+    score double precision NOT NULL,
+    post_count int NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (period, tag)
+);
+
+CREATE TABLE IF NOT EXISTS trending_posts (
+    period varchar(10) NOT NULL,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    score double precision NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (period, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trending_tags_score ON trending_tags (period, score DESC);
+CREATE INDEX IF NOT EXISTS idx_trending_posts_score ON trending_posts (period, score DESC);
+
+-- the aggregator only reads activity of the last week
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments (created_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS mentions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id bigint REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+    start_offset int NOT NULL,
+    end_offset int NOT NULL,
+    CONSTRAINT mentions_target_check CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_post_id ON mentions (post_id) WHERE post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_comment_id ON mentions (comment_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id);
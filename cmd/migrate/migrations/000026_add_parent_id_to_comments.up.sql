@@ -0,0 +1,4 @@
+ALTER TABLE comments
+ADD COLUMN parent_id bigint REFERENCES comments(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id) WHERE parent_id IS NOT NULL;
@@ -0,0 +1,15 @@
+-- no foreign key: a quote keeps pointing at its original once that is purged,
+-- so it can still be shown as a quote of an unavailable post
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS quoted_post_id bigint;
+
+CREATE INDEX IF NOT EXISTS idx_posts_quoted_post_id ON posts (quoted_post_id) WHERE quoted_post_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS reposts (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reposts_post_id ON reposts (post_id);
+CREATE INDEX IF NOT EXISTS idx_reposts_user_id ON reposts (user_id, created_at DESC);
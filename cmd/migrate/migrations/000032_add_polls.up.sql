@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS polls (
+    post_id bigint PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    multiple boolean NOT NULL DEFAULT false,
+    results_visibility varchar(20) NOT NULL DEFAULT 'always',
+    closes_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES polls(post_id) ON DELETE CASCADE,
+    position int NOT NULL,
+    label varchar(100) NOT NULL,
+    UNIQUE (post_id, position)
+);
+
+-- a ballot per voter enforces one vote per user, multiple choice or not
+CREATE TABLE IF NOT EXISTS poll_ballots (
+    post_id bigint NOT NULL REFERENCES polls(post_id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    option_id bigint NOT NULL REFERENCES poll_options(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id, option_id),
+    FOREIGN KEY (post_id, user_id) REFERENCES poll_ballots(post_id, user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_option_id ON poll_votes (option_id);
@@ -0,0 +1,22 @@
+-- uploads stay unattached, post_id NULL, until a post references them. The
+-- purger removes those left unattached, and those of purged posts.
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id bigint REFERENCES posts(id) ON DELETE SET NULL,
+    position int,
+    storage_key text NOT NULL,
+    thumbnail_key text NOT NULL,
+    url text NOT NULL,
+    thumbnail_url text NOT NULL,
+    content_type varchar(50) NOT NULL,
+    size bigint NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    alt_text varchar(1000) NOT NULL DEFAULT '',
+    attached_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_post_id ON media (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_media_unattached ON media (created_at) WHERE post_id IS NULL;
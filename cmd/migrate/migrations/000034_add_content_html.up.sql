@@ -0,0 +1,16 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_html text NOT NULL DEFAULT '';
+
+-- existing content predates Markdown, so it is kept as escaped text with its
+-- line breaks; it is rendered as Markdown the next time it's edited
+UPDATE posts SET content_html = '<p>' || replace(
+    replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+    E'\n', E'<br>\n'
+) || '</p>'
+WHERE content <> '';
+
+UPDATE comments SET content_html = '<p>' || replace(
+    replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+    E'\n', E'<br>\n'
+) || '</p>'
+WHERE content <> '';
@@ -0,0 +1,5 @@
+-- the tags set explicitly, which content edits keep even when they are also
+-- hashtags of the content. Which stored tags were explicit isn't known, so
+-- existing posts keep all of them.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS explicit_tags VARCHAR(100)[] NOT NULL DEFAULT '{}';
+UPDATE posts SET explicit_tags = tags WHERE tags IS NOT NULL;
@@ -0,0 +1,11 @@
+-- one reaction per user and post, changing its kind replaces it
+CREATE TABLE IF NOT EXISTS reactions (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    kind varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reactions_post_id ON reactions (post_id);
+CREATE INDEX IF NOT EXISTS idx_reactions_created_at ON reactions (created_at);
@@ -0,0 +1,15 @@
+-- one row per tag of a post, kept in sync with posts.tags on write, so tag
+-- autocomplete is a prefix range scan instead of unnesting every post
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    tag varchar(100) NOT NULL,
+    PRIMARY KEY (post_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_pattern ON post_tags (tag text_pattern_ops);
+
+INSERT INTO post_tags (post_id, tag)
+SELECT DISTINCT p.id, t.tag
+FROM posts p
+CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+ON CONFLICT DO NOTHING;
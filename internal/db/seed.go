@@ -211,14 +211,17 @@ func generatePosts(num int, users []*store.User) []*store.Post {
 	for i := 0; i < num; i++ {
 		user := users[rand.Intn(len(users))]
 
+		postTags := []string{
+			tags[rand.Intn(len(tags))],
+			tags[rand.Intn(len(tags))],
+		}
+
 		posts[i] = &store.Post{
-			UserID:  user.ID,
-			Title:   titles[rand.Intn(len(titles))],
-			Content: contents[rand.Intn(len(contents))],
-			Tags: []string{
-				tags[rand.Intn(len(tags))],
-				tags[rand.Intn(len(tags))],
-			},
+			UserID:       user.ID,
+			Title:        titles[rand.Intn(len(titles))],
+			Content:      contents[rand.Intn(len(contents))],
+			Tags:         postTags,
+			ExplicitTags: postTags,
 		}
 
 	}
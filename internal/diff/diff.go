@@ -0,0 +1,166 @@
+// Package diff renders line based unified diffs between two texts.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines kept around each change.
+const context = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the unified diff turning a into b, labelled with fromName
+// and toName. It returns an empty string when both texts are equal.
+func Unified(fromName, toName, a, b string) string {
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	for _, h := range hunks(ops) {
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		sb.WriteString(h)
+	}
+
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineOps computes the edit script between a and b from their longest common
+// subsequence. Post content is short, so the quadratic table is fine.
+func lineOps(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+
+	return ops
+}
+
+// hunks groups the edit script into hunks, merging changes that are less than
+// two contexts apart.
+func hunks(ops []op) []string {
+	var out []string
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		first := start
+		for first < len(ops) && ops[first].kind == opEqual {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		// extend until a run of unchanged lines long enough to split on
+		last := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != opEqual {
+				last = k
+				continue
+			}
+			if k-last > 2*context {
+				break
+			}
+		}
+
+		from := max(first-context, 0)
+		to := min(last+context+1, len(ops))
+		out = append(out, renderHunk(ops, from, to))
+
+		start = to
+	}
+
+	return out
+}
+
+func renderHunk(ops []op, from, to int) string {
+	// line numbers in a and b where the hunk starts
+	aStart, bStart := 1, 1
+	for _, o := range ops[:from] {
+		if o.kind != opInsert {
+			aStart++
+		}
+		if o.kind != opDelete {
+			bStart++
+		}
+	}
+
+	var body strings.Builder
+	aLen, bLen := 0, 0
+	for _, o := range ops[from:to] {
+		switch o.kind {
+		case opEqual:
+			body.WriteString(" " + o.line + "\n")
+			aLen++
+			bLen++
+		case opDelete:
+			body.WriteString("-" + o.line + "\n")
+			aLen++
+		case opInsert:
+			body.WriteString("+" + o.line + "\n")
+			bLen++
+		}
+	}
+
+	// an empty range starts on the line before it
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", aStart, aLen, bStart, bLen, body.String())
+}
@@ -24,3 +24,15 @@ func GetInt(key string, fallback int) int {
 	}
 	return valIsInt
 }
+
+func GetBool(key string, fallback bool) bool {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	valIsBool, err := strconv.ParseBool(val)
+	if err != nil {
+		return fallback
+	}
+	return valIsBool
+}
@@ -0,0 +1,87 @@
+// Package events carries domain events between API replicas over Postgres
+// LISTEN/NOTIFY. The store publishes them inside the transaction of the write
+// they describe, so listeners only hear about committed changes, and every
+// replica, the one making the change included, receives them through its
+// Listener.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Channel is the Postgres notification channel events travel on.
+const Channel = "social_events"
+
+const (
+	PostCreated   = "post.created"
+	PostUpdated   = "post.updated"
+	PostDeleted   = "post.deleted"
+	PostPublished = "post.published"
+	UserUpdated   = "user.updated"
+	Followed      = "follow"
+	Unfollowed    = "unfollow"
+	Streamed      = "stream"
+)
+
+// Reconnected is delivered to every handler when the listener got its
+// connection back. Notifications sent while it was down are lost, so
+// in-memory state built from events should be dropped or reloaded.
+const Reconnected = "listener.reconnected"
+
+// Event is a domain event. Payloads only carry IDs and a few fields, as
+// Postgres caps notifications at 8000 bytes; listeners load what they need.
+type Event struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type PostEvent struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Status string `json:"status,omitempty"`
+}
+
+type UserEvent struct {
+	ID int64 `json:"id"`
+}
+
+type FollowEvent struct {
+	FollowerID int64 `json:"follower_id"`
+	UserID     int64 `json:"user_id"`
+}
+
+// StreamEvent relays a real-time event to the stream hub of every replica,
+// so clients get it whichever replica they are connected to.
+type StreamEvent struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Execer is satisfied by *sql.DB and *sql.Tx. Passing the transaction of the
+// write delays delivery until it commits and drops the event on rollback.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Publish sends an event of type eventType with data as payload.
+func Publish(ctx context.Context, exec Execer, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(Event{Type: eventType, Data: raw})
+	if err != nil {
+		return err
+	}
+
+	if _, err := exec.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload)); err != nil {
+		return fmt.Errorf("publishing %s event: %w", eventType, err)
+	}
+
+	return nil
+}
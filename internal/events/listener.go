@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Handler reacts to an event. Handlers run one at a time, in the order
+// events arrive, so slow work should be handed off.
+type Handler func(ctx context.Context, e Event)
+
+// Listener receives the events published by every replica on a dedicated
+// connection, reconnecting with backoff whenever it is lost.
+type Listener struct {
+	dsn      string
+	logger   *zap.SugaredLogger
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewListener(dsn string, logger *zap.SugaredLogger) *Listener {
+	return &Listener{
+		dsn:      dsn,
+		logger:   logger,
+		handlers: map[string][]Handler{},
+	}
+}
+
+// Subscribe registers fn for the events of eventType, Reconnected included.
+func (l *Listener) Subscribe(eventType string, fn Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers[eventType] = append(l.handlers[eventType], fn)
+}
+
+// Run listens until ctx is done.
+func (l *Listener) Run(ctx context.Context) error {
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			l.logger.Warnw("events listener disconnected", "error", err)
+		case pq.ListenerEventReconnected:
+			l.logger.Infow("events listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			l.logger.Warnw("events listener connection attempt failed", "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		return err
+	}
+
+	// a connection that silently died is only noticed when pinged
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case n := <-listener.Notify:
+			// pq sends nil once the connection is back
+			if n == nil {
+				l.dispatch(ctx, Event{Type: Reconnected})
+				continue
+			}
+
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				l.logger.Errorw("malformed event", "payload", n.Extra, "error", err)
+				continue
+			}
+			l.dispatch(ctx, e)
+
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func (l *Listener) dispatch(ctx context.Context, e Event) {
+	l.mu.RLock()
+	handlers := l.handlers[e.Type]
+	l.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, e)
+	}
+}
@@ -0,0 +1,345 @@
+// Package markdown renders the restricted Markdown dialect posts and comments
+// are written in to HTML that is safe to embed as is.
+//
+// The dialect has paragraphs, with single line breaks kept, bullet lists (-,
+// * or +), numbered lists, blockquotes and fenced code blocks, and inline
+// **bold**, *italic* or _italic_, ~~strikethrough~~, `code`, [links](url) and
+// bare http(s) URLs. Backslashes escape punctuation. Headings are left out so
+// #tags at the start of a line stay text, and so are images and raw HTML:
+// every character of the source is escaped, and only the tags above are ever
+// written. Links are limited to http, https and mailto URLs and get
+// rel="nofollow noopener noreferrer".
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxQuoteDepth is how deep blockquotes nest before > is shown as text.
+const maxQuoteDepth = 4
+
+var (
+	bulletItem  = regexp.MustCompile(`^ {0,3}[-*+][ \t]+(.*)$`)
+	orderedItem = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	quoteLine   = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	fenceLine   = regexp.MustCompile("^ {0,3}```")
+)
+
+// Render converts source to HTML.
+func Render(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(source, "\n"), 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceLine.MatchString(line):
+			i++
+			start := i
+			for i < len(lines) && !fenceLine.MatchString(lines[i]) {
+				i++
+			}
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(lines[start:i], "\n")))
+			b.WriteString("</code></pre>\n")
+			// skip the closing fence, if any
+			i++
+
+		case depth < maxQuoteDepth && quoteLine.MatchString(line):
+			var inner []string
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				inner = append(inner, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, inner, depth+1)
+			b.WriteString("</blockquote>\n")
+
+		case bulletItem.MatchString(line):
+			b.WriteString("<ul>\n")
+			for ; i < len(lines) && bulletItem.MatchString(lines[i]); i++ {
+				writeItem(b, bulletItem.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("</ul>\n")
+
+		case orderedItem.MatchString(line):
+			first := orderedItem.FindStringSubmatch(line)[1]
+			if n, _ := strconv.Atoi(first); n != 1 {
+				b.WriteString(`<ol start="` + strconv.Itoa(n) + `">` + "\n")
+			} else {
+				b.WriteString("<ol>\n")
+			}
+			for ; i < len(lines) && orderedItem.MatchString(lines[i]); i++ {
+				writeItem(b, orderedItem.FindStringSubmatch(lines[i])[2])
+			}
+			b.WriteString("</ol>\n")
+
+		default:
+			start := i
+			for i++; i < len(lines) && !startsBlock(lines[i], depth); i++ {
+			}
+			b.WriteString("<p>")
+			b.WriteString(renderInline(strings.Join(lines[start:i], "\n"), true))
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// startsBlock reports whether line ends the paragraph before it.
+func startsBlock(line string, depth int) bool {
+	return strings.TrimSpace(line) == "" ||
+		fenceLine.MatchString(line) ||
+		(depth < maxQuoteDepth && quoteLine.MatchString(line)) ||
+		bulletItem.MatchString(line) ||
+		orderedItem.MatchString(line)
+}
+
+func writeItem(b *strings.Builder, text string) {
+	b.WriteString("<li>")
+	b.WriteString(renderInline(text, true))
+	b.WriteString("</li>\n")
+}
+
+// inlineTags are the emphasis delimiters, longest first so ** isn't read as
+// two *.
+var inlineTags = []struct{ delim, tag string }{
+	{"**", "strong"},
+	{"~~", "del"},
+	{"*", "em"},
+	{"_", "em"},
+}
+
+// renderInline renders the spans of text. links is false inside link text, as
+// links don't nest.
+func renderInline(text string, links bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				b.WriteString("<code>" + html.EscapeString(rest[1:1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		case c == '[' && links:
+			if label, href, n, ok := parseLink(rest); ok {
+				writeLink(&b, href, renderInline(label, false))
+				i += n
+				continue
+			}
+
+		case (c == 'h' || c == 'H') && links && !precededByWord(text, i):
+			if n := autolinkLength(rest); n > 0 {
+				writeLink(&b, rest[:n], html.EscapeString(rest[:n]))
+				i += n
+				continue
+			}
+		}
+
+		if tag, inner, n, ok := parseEmphasis(text, i); ok {
+			b.WriteString("<" + tag + ">" + renderInline(inner, links) + "</" + tag + ">")
+			i += n
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		b.WriteString(html.EscapeString(rest[:size]))
+		i += size
+	}
+
+	return b.String()
+}
+
+// parseEmphasis matches a delimiter run at text[i] with its closing one. The
+// opening delimiter must be followed, and the closing one preceded, by a
+// non-space, and _ only works at word boundaries so snake_case stays text.
+func parseEmphasis(text string, i int) (tag, inner string, n int, ok bool) {
+	for _, t := range inlineTags {
+		d := t.delim
+		if !strings.HasPrefix(text[i:], d) {
+			continue
+		}
+		if d == "_" && precededByWord(text, i) {
+			return "", "", 0, false
+		}
+
+		body := text[i+len(d):]
+		if body == "" || isSpace(body[0]) {
+			continue
+		}
+
+		for j := 1; j < len(body); j++ {
+			if !strings.HasPrefix(body[j:], d) || isSpace(body[j-1]) {
+				continue
+			}
+			// a single * or _ next to another one is part of a longer run
+			if len(d) == 1 && (body[j-1] == d[0] || (j+1 < len(body) && body[j+1] == d[0])) {
+				continue
+			}
+			if d == "_" && j+1 < len(body) && isWordByte(body[j+1]) {
+				continue
+			}
+			return t.tag, body[:j], len(d)*2 + j, true
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// parseLink matches [label](url) at the start of s, returning how many bytes
+// it spans.
+func parseLink(s string) (label, href string, n int, ok bool) {
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if i == 1 || !strings.HasPrefix(s[i+1:], "(") {
+				return "", "", 0, false
+			}
+			end := closingParen(s[i+2:])
+			if end < 0 {
+				return "", "", 0, false
+			}
+			href = strings.TrimSpace(s[i+2 : i+2+end])
+			if href == "" || strings.ContainsAny(href, " \t\n") {
+				return "", "", 0, false
+			}
+			return s[1:i], href, i + 3 + end, true
+		case '\n':
+			return "", "", 0, false
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// closingParen returns the index of the ) closing the link URL s starts
+// with, skipping balanced pairs such as in wiki/Go_(lang), or -1.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// autolinkLength returns the length of the http(s) URL at the start of s, or
+// 0. Trailing punctuation is left out, and so is a closing parenthesis
+// without its opening one, as in "(see https://example.com)".
+func autolinkLength(s string) int {
+	lower := strings.ToLower(s)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return 0
+	}
+
+	n := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '<' || r == '>' })
+	if n < 0 {
+		n = len(s)
+	}
+
+	for n > 0 {
+		last := s[n-1]
+		if strings.IndexByte(".,;:!?'\"*_~", last) >= 0 ||
+			(last == ')' && strings.Count(s[:n], "(") < strings.Count(s[:n], ")")) {
+			n--
+			continue
+		}
+		break
+	}
+
+	if n <= strings.Index(s, "//")+2 {
+		return 0
+	}
+	return n
+}
+
+// writeLink writes a link to href around label, already rendered. URLs with
+// other schemes, such as javascript:, only get their label written.
+func writeLink(b *strings.Builder, href, label string) {
+	u, err := url.Parse(href)
+	if err != nil {
+		b.WriteString(label)
+		return
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			b.WriteString(label)
+			return
+		}
+	case "mailto":
+	default:
+		b.WriteString(label)
+		return
+	}
+
+	b.WriteString(`<a href="` + html.EscapeString(u.String()) + `" rel="nofollow noopener noreferrer">`)
+	b.WriteString(label)
+	b.WriteString("</a>")
+}
+
+func precededByWord(text string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("`*_~[]()<>#+-.!\\", c) >= 0
+}
@@ -0,0 +1,105 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+const rel = ` rel="nofollow noopener noreferrer"`
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"paragraph with line break", "one\ntwo", "<p>one<br>\ntwo</p>"},
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"script in code block", "```\n<script>x</script>\n```", "<pre><code>&lt;script&gt;x&lt;/script&gt;</code></pre>"},
+		{"emphasis", "**bold** *it* _it_ ~~gone~~", "<p><strong>bold</strong> <em>it</em> <em>it</em> <del>gone</del></p>"},
+		{"snake_case", "call snake_case_name now", "<p>call snake_case_name now</p>"},
+		{"escaped delimiter", `\*not italic\*`, "<p>*not italic*</p>"},
+		{"code span", "`<b>`", "<p><code>&lt;b&gt;</code></p>"},
+		{"bullet list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>"},
+		{"numbered list", "3. a\n4. b", "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>"},
+		{"hashtag at line start", "#golang rocks", "<p>#golang rocks</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.source); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderLinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"http link", "[site](https://example.com)", `<p><a href="https://example.com"` + rel + `>site</a></p>`},
+		{"mailto link", "[mail](mailto:a@example.com)", `<p><a href="mailto:a@example.com"` + rel + `>mail</a></p>`},
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>"},
+		{"javascript link in caps", "[x](JavaScript:alert(1))", "<p>x</p>"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>"},
+		{"protocol relative link", "[x](//evil.example)", "<p>x</p>"},
+		{"relative link", "[x](/admin)", "<p>x</p>"},
+		{"http link without host", "[x](http:evil)", "<p>x</p>"},
+		{"quote breakout", `[x](https://example.com/"onmouseover="alert(1))`, `<p><a href="https://example.com/%22onmouseover=%22alert%281%29"` + rel + `>x</a></p>`},
+		{"angle bracket breakout", `[x](https://example.com/"><script>)`, `<p><a href="https://example.com/%22%3E%3Cscript%3E"` + rel + `>x</a></p>`},
+		{"label is rendered", "[**b**](https://example.com)", `<p><a href="https://example.com"` + rel + `><strong>b</strong></a></p>`},
+		{"links don't nest", "[[in](https://a.example)](https://b.example)", `<p><a href="https://b.example"` + rel + `>[in](https://a.example)</a></p>`},
+		{"balanced parens", "[go](https://en.wikipedia.org/wiki/Go_(lang))", `<p><a href="https://en.wikipedia.org/wiki/Go_(lang)"` + rel + `>go</a></p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.source); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderAutolinks(t *testing.T) {
+	link := func(href string) string {
+		return `<a href="` + href + `"` + rel + `>` + href + `</a>`
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"bare url", "see https://example.com/a", "<p>see " + link("https://example.com/a") + "</p>"},
+		{"trailing period", "at https://example.com.", "<p>at " + link("https://example.com") + ".</p>"},
+		{"trailing punctuation run", "really https://example.com/a?!", "<p>really " + link("https://example.com/a") + "?!</p>"},
+		{"unbalanced closing paren", "(see https://example.com)", "<p>(see " + link("https://example.com") + ")</p>"},
+		{"balanced parens kept", "https://example.com/Go_(lang)", "<p>" + link("https://example.com/Go_(lang)") + "</p>"},
+		{"scheme only", "https://.", "<p>https://.</p>"},
+		{"inside a word", "xhttps://example.com", "<p>xhttps://example.com</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.source); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderQuoteDepth(t *testing.T) {
+	source := strings.Repeat("> ", maxQuoteDepth+1) + "deep"
+
+	got := Render(source)
+	if n := strings.Count(got, "<blockquote>"); n != maxQuoteDepth {
+		t.Errorf("Render(%q) nests %d blockquotes, want %d", source, n, maxQuoteDepth)
+	}
+	if !strings.Contains(got, "<p>&gt; deep</p>") {
+		t.Errorf("Render(%q) = %q, want the > past the limit shown as text", source, got)
+	}
+}
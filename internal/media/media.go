@@ -0,0 +1,136 @@
+// Package media validates uploaded images and makes their thumbnails.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type, upload a JPEG, PNG or GIF image")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// formats maps the content types accepted, as sniffed from the first bytes of
+// the file, to the image package format name and the extension objects get.
+var formats = map[string]struct{ name, ext string }{
+	"image/jpeg": {"jpeg", ".jpg"},
+	"image/png":  {"png", ".png"},
+	"image/gif":  {"gif", ".gif"},
+}
+
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	image       image.Image
+}
+
+// Decode checks that data is an image of an accepted type by its magic bytes,
+// whatever the file name or declared type say, and decodes it. The header is
+// read first so images over maxPixels are rejected before being decoded.
+func Decode(data []byte, maxPixels int) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	format, ok := formats[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || name != format.name {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	// GIFs decode to their first frame, which is all a thumbnail needs
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	return &Image{
+		ContentType: contentType,
+		Ext:         format.ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		image:       img,
+	}, nil
+}
+
+// Thumbnail scales the image down to fit in a size by size square, keeping
+// its aspect ratio, and encodes it as a JPEG, or as a PNG when it has
+// transparency. Smaller images are re-encoded at their size.
+func (img *Image) Thumbnail(size int) (data []byte, contentType string, err error) {
+	thumb := scaleDown(img.image, size)
+
+	var buf bytes.Buffer
+	if opaque(thumb) {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+		contentType = "image/jpeg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		contentType = "image/png"
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), contentType, nil
+}
+
+// scaleDown averages the source pixels covered by each destination pixel,
+// which is good enough for thumbnails without pulling an imaging library.
+func scaleDown(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	dw, dh := size, size
+	if w > h {
+		dh = max(1, h*size/w)
+	} else {
+		dw = max(1, w*size/h)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := range dw {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(src.At(sx, sy)).(color.NRGBA)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+
+	return dst
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+// Package mentions finds @username mentions in post and comment content.
+package mentions
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxPerContent is the most mentions kept from a single post or comment,
+// so one can't be used to notify half the site.
+const MaxPerContent = 20
+
+// mentionExpr doesn't match after letters or digits, which keeps email
+// addresses out.
+var mentionExpr = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])(@[\p{L}\p{N}_][\p{L}\p{N}_.-]*)`)
+
+// Match is a mention found in content. Start and End are offsets in
+// characters (Unicode code points), the @ included, End excluded.
+type Match struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// Parse returns the mentions of content in order of appearance. A trailing
+// '.' or '-' is taken as punctuation rather than part of the username.
+func Parse(content string) []Match {
+	var out []Match
+
+	for _, loc := range mentionExpr.FindAllStringSubmatchIndex(content, -1) {
+		if len(out) == MaxPerContent {
+			break
+		}
+
+		start, end := loc[2], loc[3]
+		end -= len(content[start:end]) - len(strings.TrimRight(content[start:end], ".-"))
+
+		out = append(out, Match{
+			Username: content[start+1 : end],
+			Start:    utf8.RuneCountInString(content[:start]),
+			End:      utf8.RuneCountInString(content[:end]),
+		})
+	}
+
+	return out
+}
@@ -0,0 +1,51 @@
+package mentions
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Match
+	}{
+		{"start of content", "@alice hi", []Match{{"alice", 0, 6}}},
+		{"after text", "hi @bob!", []Match{{"bob", 3, 7}}},
+		{"multibyte text before", "héllo 👋 @carol", []Match{{"carol", 8, 14}}},
+		{"non-latin username", "привет @пётр", []Match{{"пётр", 7, 12}}},
+		{"trailing period", "thanks @dave.", []Match{{"dave", 7, 12}}},
+		{"trailing dashes", "@eve-- ok", []Match{{"eve", 0, 4}}},
+		{"inner dot and dash", "@f.g-h", []Match{{"f.g-h", 0, 6}}},
+		{"email address", "mail a@b.com", nil},
+		{"after a dot", "x.@frank", nil},
+		{"bare at", "@ nobody", nil},
+		{"several", "@a, @b and (@c)", []Match{{"a", 0, 2}, {"b", 4, 6}, {"c", 12, 14}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapsMentions(t *testing.T) {
+	var names []string
+	for i := 0; i < MaxPerContent+5; i++ {
+		names = append(names, "@user"+strconv.Itoa(i))
+	}
+
+	got := Parse(strings.Join(names, " "))
+	if len(got) != MaxPerContent {
+		t.Fatalf("Parse() returned %d mentions, want %d", len(got), MaxPerContent)
+	}
+	if last := got[len(got)-1].Username; last != "user"+strconv.Itoa(MaxPerContent-1) {
+		t.Errorf("last mention kept is %q, want the first %d in order", last, MaxPerContent)
+	}
+}
@@ -0,0 +1,40 @@
+// Package objectstore keeps uploaded files, such as media attachments, behind
+// a small interface so the API doesn't care where the bytes end up.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Store saves objects under slash separated keys. Implementations must be
+// safe for concurrent use. The local filesystem one is meant for development
+// and single node deployments; an S3 compatible one can be added by
+// implementing the same methods.
+type Store interface {
+	// Put saves body under key, replacing any object already there.
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// Get opens the object under key, failing with ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Deleting a missing object is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the object under key from.
+	URL(key string) string
+}
+
+// validKey rejects keys that are empty, absolute or escape the store root.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"social/internal/events"
+
+	"github.com/lib/pq"
+)
+
+type Block struct {
+	BlockerID int64  `json:"blocker_id"`
+	BlockedID int64  `json:"blocked_id"`
+	CreatedAt string `json:"created_at"`
+	User      User   `json:"user"`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// blockedClause is a SQL condition that is true when the user in column
+// userCol and the user bound to viewerParam have blocked each other in either direction.
+func blockedClause(userCol, viewerParam string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = %[2]s AND ub.blocked_id = %[1]s)
+		OR (ub.blocker_id = %[1]s AND ub.blocked_id = %[2]s)
+	)`, userCol, viewerParam)
+}
+
+// Block records the block and tears down every follow relationship and
+// pending request between the two users in the same transaction, emitting an
+// unfollow event for each follow. Blocking a user that doesn't exist is
+// reported as ErrNotFound.
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case "23505":
+					return ErrConflict
+				case "23503":
+					return ErrNotFound
+				}
+			}
+			return err
+		}
+
+		query = `
+		DELETE FROM followers
+		WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		RETURNING follower_id, user_id
+		`
+		rows, err := tx.QueryContext(ctx, query, blockerID, blockedID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		unfollows := []events.FollowEvent{}
+		for rows.Next() {
+			var e events.FollowEvent
+			if err := rows.Scan(&e.FollowerID, &e.UserID); err != nil {
+				return err
+			}
+			unfollows = append(unfollows, e)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM follow_requests WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		// the follows torn down end like any unfollow
+		for _, e := range unfollows {
+			if err := events.Publish(ctx, tx, events.Unfollowed, e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT ` + blockedClause("$2", "$1")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
+
+func (s *BlockStore) GetBlocked(ctx context.Context, blockerID int64) ([]Block, error) {
+	query := `
+	SELECT ub.blocker_id, ub.blocked_id, ub.created_at, u.id, u.username
+	FROM user_blocks ub
+	JOIN users u ON u.id = ub.blocked_id
+	WHERE ub.blocker_id = $1
+	ORDER BY ub.created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []Block{}
+
+	for rows.Next() {
+		var b Block
+
+		if err := rows.Scan(&b.BlockerID, &b.BlockedID, &b.CreatedAt, &b.User.ID, &b.User.UserName); err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type BookmarkCollection struct {
+	ID            int64  `json:"id"`
+	UserID        int64  `json:"user_id"`
+	Name          string `json:"name"`
+	BookmarkCount int    `json:"bookmark_count"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Add bookmarks a post for userID, filing it in collectionID when it isn't
+// nil. Bookmarking it again moves it to the new collection. A collection that
+// doesn't belong to userID is reported as ErrNotFound.
+func (s *BookmarkStore) Add(ctx context.Context, userID, postID int64, collectionID *int64) error {
+	query := `
+	INSERT INTO bookmarks (user_id, post_id, collection_id)
+	SELECT $1, $2, $3
+	WHERE $3::bigint IS NULL OR EXISTS (SELECT 1 FROM bookmark_collections WHERE id = $3 AND user_id = $1)
+	ON CONFLICT (user_id, post_id) DO UPDATE SET collection_id = EXCLUDED.collection_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID, collectionID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) Remove(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetPosts returns the posts bookmarked by userID, most recently bookmarked
+// first, only the ones in collectionID when it isn't nil. Posts userID can no
+// longer read are left out.
+func (s *BookmarkStore) GetPosts(ctx context.Context, userID int64, collectionID *int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$1") + `
+	FROM bookmarks b
+	JOIN posts p ON p.id = b.post_id
+	JOIN users u ON p.user_id = u.id
+	WHERE
+		b.user_id = $1 AND
+		($4::bigint IS NULL OR b.collection_id = $4) AND
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		` + postVisibleClause("p", "u", "$1") + ` AND
+		` + fq.filterClause("p", 5) + `
+	ORDER BY b.created_at ` + fq.Sort + `, p.id ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	args := append([]any{userID, fq.Limit, fq.Offset, collectionID}, fq.filterArgs()...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostsWithMetaData(rows)
+}
+
+// BookmarkCollectionStore manages the named collections bookmarks are filed
+// in. Collections are private, every method is scoped to their owner.
+type BookmarkCollectionStore struct {
+	db *sql.DB
+}
+
+func (s *BookmarkCollectionStore) Create(ctx context.Context, c *BookmarkCollection) error {
+	query := `INSERT INTO bookmark_collections (user_id, name) VALUES ($1, $2) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, c.UserID, c.Name).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *BookmarkCollectionStore) GetByID(ctx context.Context, id, userID int64) (*BookmarkCollection, error) {
+	query := `
+	SELECT c.id, c.user_id, c.name, c.created_at,
+		(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = c.id)
+	FROM bookmark_collections c WHERE c.id = $1 AND c.user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var c BookmarkCollection
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt, &c.BookmarkCount)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+func (s *BookmarkCollectionStore) GetByUserID(ctx context.Context, userID int64) ([]BookmarkCollection, error) {
+	query := `
+	SELECT c.id, c.user_id, c.name, c.created_at,
+		(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = c.id)
+	FROM bookmark_collections c WHERE c.user_id = $1
+	ORDER BY lower(c.name)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []BookmarkCollection{}
+	for rows.Next() {
+		var c BookmarkCollection
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt, &c.BookmarkCount); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+func (s *BookmarkCollectionStore) Rename(ctx context.Context, c *BookmarkCollection) error {
+	query := `UPDATE bookmark_collections SET name = $1 WHERE id = $2 AND user_id = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, c.Name, c.ID, c.UserID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a collection. Its bookmarks are kept, outside any collection.
+func (s *BookmarkCollectionStore) Delete(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -3,40 +3,53 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"social/internal/markdown"
+	"time"
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID       int64  `json:"id"`
+	PostID   int64  `json:"post_id"`
+	ParentID *int64 `json:"parent_id"`
+	UserID   int64  `json:"user_id"`
+	Content  string `json:"content"`
+	// ContentHTML is Content rendered from Markdown, set by the store on write
+	ContentHTML string     `json:"content_html"`
+	CreatedAt   string     `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Mentions    []Mention  `json:"mentions"`
+	User        User       `json:"user"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
+// GetByPostID returns the comments on a post, leaving out the ones written by
+// users that have a block with viewerID in either direction.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]*Comment, error) {
 	query := `
 	SELECT 
 		c.id, 
 		c.post_id,
+		c.parent_id,
 		c.user_id,
 		c.content,
+		c.content_html,
 		c.created_at, 
 		u.username, 
-		u.id
+		u.id,
+		` + mentionsColumn("comment_id", "c") + `
 	FROM comments c
 	JOIN users u ON u.id = c.user_id
-	WHERE c.post_id = $1
+	WHERE c.post_id = $1 AND c.deleted_at IS NULL AND NOT ` + blockedClause("c.user_id", "$2") + `
 	ORDER BY c.created_at DESC;
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -51,11 +64,14 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Commen
 		err := rows.Scan(
 			&c.ID,
 			&c.PostID,
+			&c.ParentID,
 			&c.UserID,
 			&c.Content,
+			&c.ContentHTML,
 			&c.CreatedAt,
 			&c.User.UserName,
 			&c.User.ID,
+			(*mentionsJSON)(&c.Mentions),
 		)
 		if err != nil {
 			return nil, err
@@ -67,18 +83,152 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Commen
 	return comments, nil
 }
 
+// Create adds a comment to a post along with its mentions, resolved like in
+// PostStore.Create, and its content rendered from Markdown. Commenting on a
+// post whose author has a block with the commenter in either direction, or
+// replying to a comment that isn't on the post anymore, is reported as
+// ErrNotFound.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
-	query := `INSERT INTO comments(post_id,user_id,content) VALUES($1,$2,$3) RETURNING id, created_at`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO comments(post_id,user_id,content,parent_id,content_html)
+		SELECT p.id, $2, $3, $4, $5 FROM posts p
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND NOT ` + blockedClause("p.user_id", "$2") + ` AND
+			($4::bigint IS NULL OR EXISTS (
+				SELECT 1 FROM comments pc WHERE pc.id = $4 AND pc.post_id = p.id AND pc.deleted_at IS NULL
+			))
+		RETURNING id, created_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		comment.ContentHTML = markdown.Render(comment.Content)
+
+		err := tx.QueryRowContext(
+			ctx, query, comment.PostID, comment.UserID, comment.Content, comment.ParentID, comment.ContentHTML,
+		).Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		comment.Mentions, err = replaceMentions(ctx, tx, "comment_id", comment.ID, comment.UserID, comment.Mentions)
+		return err
+	})
+}
+
+// Update saves the content of a comment that isn't in the trash, replacing
+// its mentions and rendering it like Create does.
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE comments SET content = $1, content_html = $3 WHERE id = $2 AND deleted_at IS NULL`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		comment.ContentHTML = markdown.Render(comment.Content)
+		res, err := tx.ExecContext(ctx, query, comment.Content, comment.ID, comment.ContentHTML)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		comment.Mentions, err = replaceMentions(ctx, tx, "comment_id", comment.ID, comment.UserID, comment.Mentions)
+		return err
+	})
+}
+
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	return s.getComment(ctx, `c.id = $1 AND c.deleted_at IS NULL`, id)
+}
+
+// GetTrashedByID returns a soft deleted comment, which GetByID no longer finds.
+func (s *CommentStore) GetTrashedByID(ctx context.Context, id int64) (*Comment, error) {
+	return s.getComment(ctx, `c.id = $1 AND c.deleted_at IS NOT NULL`, id)
+}
+
+func (s *CommentStore) getComment(ctx context.Context, where string, args ...any) (*Comment, error) {
+	query := `
+	SELECT c.id, c.post_id, c.parent_id, c.user_id, c.content, c.content_html, c.created_at, c.deleted_at, u.id,
+		u.username,
+		` + mentionsColumn("comment_id", "c") + `
+	FROM comments c
+	JOIN users u ON u.id = c.user_id
+	WHERE ` + where
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx, query, comment.PostID, comment.UserID, comment.Content,
-	).Scan(&comment.ID, &comment.CreatedAt)
+	c := &Comment{}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&c.ID, &c.PostID, &c.ParentID, &c.UserID, &c.Content, &c.ContentHTML, &c.CreatedAt, &c.DeletedAt, &c.User.ID,
+		&c.User.UserName,
+		(*mentionsJSON)(&c.Mentions),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Delete moves a comment to the trash. It stays restorable until Purge removes it.
+func (s *CommentStore) Delete(ctx context.Context, id int64) error {
+	return s.setDeleted(ctx, `UPDATE comments SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+}
+
+func (s *CommentStore) Restore(ctx context.Context, id int64) error {
+	return s.setDeleted(ctx, `UPDATE comments SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+}
+
+func (s *CommentStore) setDeleted(ctx context.Context, query string, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
 
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
+
+// Purge hard deletes the comments trashed before the given time.
+func (s *CommentStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM comments WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxConversationParticipants caps group conversations, creator included.
+const MaxConversationParticipants = 10
+
+type Conversation struct {
+	ID            int64                     `json:"id"`
+	IsGroup       bool                      `json:"is_group"`
+	Title         *string                   `json:"title"`
+	CreatedBy     *int64                    `json:"created_by"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	LastMessageAt time.Time                 `json:"last_message_at"`
+	Participants  []ConversationParticipant `json:"participants"`
+	LastMessage   *Message                  `json:"last_message"`
+	UnreadCount   int                       `json:"unread_count"`
+}
+
+// ConversationParticipant is a member of a conversation. LastReadMessageID is
+// their read cursor, every message up to it counts as read by them.
+type ConversationParticipant struct {
+	UserID            int64     `json:"user_id"`
+	Username          string    `json:"username"`
+	LastReadMessageID int64     `json:"last_read_message_id"`
+	JoinedAt          time.Time `json:"joined_at"`
+}
+
+type ConversationStore struct {
+	db *sql.DB
+}
+
+// participantsJSON scans the JSON array built by the participants column.
+type participantsJSON []ConversationParticipant
+
+func (p *participantsJSON) Scan(src any) error {
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("participants: expected a JSON array")
+	}
+	return json.Unmarshal(data, (*[]ConversationParticipant)(p))
+}
+
+// lastMessageJSON scans the JSON object of the last message, if any.
+type lastMessageJSON struct{ m **Message }
+
+func (l lastMessageJSON) Scan(src any) error {
+	if src == nil {
+		*l.m = nil
+		return nil
+	}
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("last message: expected a JSON object")
+	}
+	return json.Unmarshal(data, l.m)
+}
+
+// reachableClause is a SQL condition that is true when the user aliased as
+// userAlias is active and may be messaged by the user bound to senderParam: neither blocked the other,
+// and private accounts only hear from their followers.
+func reachableClause(userAlias, senderParam string) string {
+	return fmt.Sprintf(`(%[1]s.is_active AND NOT %[3]s AND (NOT %[1]s.is_private OR EXISTS (
+		SELECT 1 FROM followers rf WHERE rf.user_id = %[1]s.id AND rf.follower_id = %[2]s
+	)))`, userAlias, senderParam, blockedClause(userAlias+".id", senderParam))
+}
+
+// conversationSelect selects the conversations joined as me, the participant
+// row of the viewer bound to viewerParam. Messages of users that have a block
+// with the viewer are left out of the last message and the unread count.
+func conversationSelect(viewerParam string) string {
+	return fmt.Sprintf(`
+	SELECT c.id, c.is_group, c.title, c.created_by, c.created_at, c.last_message_at,
+		(
+			SELECT json_agg(json_build_object(
+				'user_id', cp.user_id, 'username', u.username,
+				'last_read_message_id', cp.last_read_message_id, 'joined_at', cp.joined_at
+			) ORDER BY cp.joined_at, cp.user_id)
+			FROM conversation_participants cp JOIN users u ON u.id = cp.user_id
+			WHERE cp.conversation_id = c.id
+		),
+		(
+			SELECT json_build_object(
+				'id', m.id, 'conversation_id', m.conversation_id, 'sender_id', m.sender_id,
+				'content', m.content, 'created_at', m.created_at,
+				'sender', json_build_object('id', su.id, 'username', su.username)
+			)
+			FROM messages m JOIN users su ON su.id = m.sender_id
+			WHERE m.conversation_id = c.id AND NOT %[2]s
+			ORDER BY m.id DESC LIMIT 1
+		),
+		(
+			SELECT COUNT(*) FROM messages m
+			WHERE m.conversation_id = c.id AND m.id > me.last_read_message_id
+			AND m.sender_id <> %[1]s AND NOT %[2]s
+		)
+	FROM conversations c
+	JOIN conversation_participants me ON me.conversation_id = c.id AND me.user_id = %[1]s
+	`, viewerParam, blockedClause("m.sender_id", viewerParam))
+}
+
+func scanConversation(row interface{ Scan(...any) error }, c *Conversation) error {
+	return row.Scan(
+		&c.ID, &c.IsGroup, &c.Title, &c.CreatedBy, &c.CreatedAt, &c.LastMessageAt,
+		(*participantsJSON)(&c.Participants), lastMessageJSON{&c.LastMessage}, &c.UnreadCount,
+	)
+}
+
+// Create starts a conversation between its creator and participantIDs, a 1:1
+// conversation when there is a single participant. Every participant must be
+// an active user reachable by the creator, see reachableClause, otherwise
+// ErrNotFound or ErrBlocked is returned. A pair of users only ever has one
+// 1:1 conversation: when it already exists its ID is set and ErrConflict is
+// returned.
+func (s *ConversationStore) Create(ctx context.Context, c *Conversation, participantIDs []int64) error {
+	creatorID := *c.CreatedBy
+	c.IsGroup = len(participantIDs) > 1
+
+	var directKey *string
+	if !c.IsGroup {
+		a, b := creatorID, participantIDs[0]
+		if a > b {
+			a, b = b, a
+		}
+		key := fmt.Sprintf("%d:%d", a, b)
+		directKey = &key
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		var found, reachable int
+		query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE ` + reachableClause("u", "$2") + `)
+		FROM users u WHERE u.id = ANY($1) AND u.is_active
+		`
+		if err := tx.QueryRowContext(ctx, query, pq.Array(participantIDs), creatorID).Scan(&found, &reachable); err != nil {
+			return err
+		}
+		if found != len(participantIDs) {
+			return ErrNotFound
+		}
+		if reachable != found {
+			return ErrBlocked
+		}
+
+		query = `
+		INSERT INTO conversations (is_group, title, direct_key, created_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (direct_key) DO NOTHING
+		RETURNING id, created_at, last_message_at
+		`
+		err := tx.QueryRowContext(ctx, query, c.IsGroup, c.Title, directKey, creatorID).Scan(
+			&c.ID, &c.CreatedAt, &c.LastMessageAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = tx.QueryRowContext(ctx, `SELECT id FROM conversations WHERE direct_key = $1`, *directKey).Scan(&c.ID)
+			if err != nil {
+				return err
+			}
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		query = `
+		INSERT INTO conversation_participants (conversation_id, user_id)
+		SELECT $1, unnest($2::bigint[])
+		`
+		_, err = tx.ExecContext(ctx, query, c.ID, pq.Array(append([]int64{creatorID}, participantIDs...)))
+		return err
+	})
+}
+
+// GetByID returns a conversation userID takes part in.
+func (s *ConversationStore) GetByID(ctx context.Context, id, userID int64) (*Conversation, error) {
+	query := conversationSelect("$2") + ` WHERE c.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var c Conversation
+	if err := scanConversation(s.db.QueryRowContext(ctx, query, id, userID), &c); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// GetByUserID returns the conversations of userID, most recently active first.
+func (s *ConversationStore) GetByUserID(ctx context.Context, userID int64, limit, offset int) ([]Conversation, error) {
+	query := conversationSelect("$1") + `
+	ORDER BY c.last_message_at DESC, c.id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var c Conversation
+		if err := scanConversation(rows, &c); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, c)
+	}
+
+	return conversations, rows.Err()
+}
+
+// MarkRead moves the read cursor of userID in the conversation up to
+// messageID, which has to belong to it. The cursor never moves back.
+func (s *ConversationStore) MarkRead(ctx context.Context, id, userID, messageID int64) error {
+	query := `
+	UPDATE conversation_participants SET last_read_message_id = GREATEST(last_read_message_id, $3)
+	WHERE conversation_id = $1 AND user_id = $2
+	AND EXISTS (SELECT 1 FROM messages WHERE id = $3 AND conversation_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	FeedFilterKeyword = "keyword"
+	FeedFilterTag     = "tag"
+)
+
+type FeedFilter struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Value     string     `json:"value"`
+	WholeWord bool       `json:"whole_word"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type FeedFilterStore struct {
+	db *sql.DB
+}
+
+// feedFilterClause is a SQL condition that is true when the post aliased as
+// postAlias matches one of the active filters of the user bound to viewerParam.
+// It is applied before LIMIT/OFFSET so filtered feeds still return full pages.
+// Keyword values are escaped before being used as a regular expression.
+func feedFilterClause(postAlias, viewerParam string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM feed_filters ff
+		WHERE ff.user_id = %[2]s
+		AND (ff.expires_at IS NULL OR ff.expires_at > NOW())
+		AND (
+			(ff.kind = 'tag' AND EXISTS (SELECT 1 FROM unnest(%[1]s.tags) t WHERE lower(t) = ff.value))
+			OR (ff.kind = 'keyword' AND NOT ff.whole_word AND (
+				strpos(lower(%[1]s.title), ff.value) > 0 OR strpos(lower(%[1]s.content), ff.value) > 0
+			))
+			OR (ff.kind = 'keyword' AND ff.whole_word AND (%[1]s.title || ' ' || %[1]s.content) ~*
+				('\m' || regexp_replace(ff.value, '([^[:alnum:][:space:]])', '\\\1', 'g') || '\M'))
+		)
+	)`, postAlias, viewerParam)
+}
+
+func (s *FeedFilterStore) Create(ctx context.Context, filter *FeedFilter) error {
+	query := `
+	INSERT INTO feed_filters (user_id, kind, value, whole_word, expires_at)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx, query, filter.UserID, filter.Kind, filter.Value, filter.WholeWord, filter.ExpiresAt,
+	).Scan(&filter.ID, &filter.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *FeedFilterStore) GetByID(ctx context.Context, id, userID int64) (*FeedFilter, error) {
+	query := `
+	SELECT id, user_id, kind, value, whole_word, expires_at, created_at
+	FROM feed_filters WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var f FeedFilter
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&f.ID, &f.UserID, &f.Kind, &f.Value, &f.WholeWord, &f.ExpiresAt, &f.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &f, nil
+}
+
+func (s *FeedFilterStore) GetByUserID(ctx context.Context, userID int64) ([]FeedFilter, error) {
+	query := `
+	SELECT id, user_id, kind, value, whole_word, expires_at, created_at
+	FROM feed_filters WHERE user_id = $1
+	ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	filters := []FeedFilter{}
+
+	for rows.Next() {
+		var f FeedFilter
+
+		err := rows.Scan(&f.ID, &f.UserID, &f.Kind, &f.Value, &f.WholeWord, &f.ExpiresAt, &f.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	return filters, rows.Err()
+}
+
+func (s *FeedFilterStore) Update(ctx context.Context, filter *FeedFilter) error {
+	query := `
+	UPDATE feed_filters SET kind = $1, value = $2, whole_word = $3, expires_at = $4
+	WHERE id = $5 AND user_id = $6
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(
+		ctx, query, filter.Kind, filter.Value, filter.WholeWord, filter.ExpiresAt, filter.ID, filter.UserID,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *FeedFilterStore) Delete(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM feed_filters WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"social/internal/events"
+)
+
+const (
+	FollowRequestPending  = "pending"
+	FollowRequestAccepted = "accepted"
+	FollowRequestRejected = "rejected"
+)
+
+type FollowRequest struct {
+	UserID     int64  `json:"user_id"`
+	FollowerID int64  `json:"follower_id"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+	Follower   User   `json:"follower"`
+}
+
+type FollowRequestStore struct {
+	db *sql.DB
+}
+
+// Create files a pending request from followerID to follow the private account userID.
+// A previously rejected request can be filed again, a pending or accepted one can't.
+func (s *FollowRequestStore) Create(ctx context.Context, followerID, userID int64) error {
+	query := `
+	INSERT INTO follow_requests (user_id, follower_id) VALUES ($1, $2)
+	ON CONFLICT (user_id, follower_id) DO UPDATE
+	SET status = 'pending', created_at = NOW(), updated_at = NOW()
+	WHERE follow_requests.status = 'rejected'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+func (s *FollowRequestStore) GetIncoming(ctx context.Context, userID int64, status string) ([]FollowRequest, error) {
+	query := `
+	SELECT fr.user_id, fr.follower_id, fr.status, fr.created_at, fr.updated_at, u.id, u.username
+	FROM follow_requests fr
+	JOIN users u ON u.id = fr.follower_id
+	WHERE fr.user_id = $1 AND fr.status = $2
+	ORDER BY fr.created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+
+	for rows.Next() {
+		var fr FollowRequest
+
+		err := rows.Scan(
+			&fr.UserID,
+			&fr.FollowerID,
+			&fr.Status,
+			&fr.CreatedAt,
+			&fr.UpdatedAt,
+			&fr.Follower.ID,
+			&fr.Follower.UserName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// Accept approves a pending request and creates the follow relationship in the same transaction.
+func (s *FollowRequestStore) Accept(ctx context.Context, userID, followerID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.setStatus(ctx, tx, userID, followerID, FollowRequestAccepted); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+			return err
+		}
+
+		return events.Publish(ctx, tx, events.Followed, events.FollowEvent{FollowerID: followerID, UserID: userID})
+	})
+}
+
+func (s *FollowRequestStore) Reject(ctx context.Context, userID, followerID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.setStatus(ctx, tx, userID, followerID, FollowRequestRejected)
+	})
+}
+
+// Delete removes any request between the two users, e.g. when the follower cancels it.
+func (s *FollowRequestStore) Delete(ctx context.Context, userID, followerID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	return err
+}
+
+func (s *FollowRequestStore) setStatus(ctx context.Context, tx *sql.Tx, userID, followerID int64, status string) error {
+	query := `
+	UPDATE follow_requests SET status = $1, updated_at = NOW()
+	WHERE user_id = $2 AND follower_id = $3 AND status = 'pending'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, status, userID, followerID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
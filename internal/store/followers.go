@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"social/internal/events"
 
 	"github.com/lib/pq"
 )
@@ -17,28 +18,91 @@ type FollowerStore struct {
 }
 
 func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
-	query := `INSERT INTO followers (user_id,follower_id) VALUES ($1, $2)`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO followers (user_id,follower_id) SELECT $1, $2 WHERE NOT ` + blockedClause("$1", "$2")
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
-	defer cancel()
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrConflict
+		res, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrBlocked
 		}
-	}
 
-	return nil
+		return events.Publish(ctx, tx, events.Followed, events.FollowEvent{FollowerID: followerID, UserID: userID})
+	})
 }
 
+// Unfollow removes the follow, emitting an event only when there was one.
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
-	query := `DELETE FROM followers
-	WHERE user_id =$1 AND follower_id = $2 `
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `DELETE FROM followers
+		WHERE user_id =$1 AND follower_id = $2 `
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+
+		return events.Publish(ctx, tx, events.Unfollowed, events.FollowEvent{FollowerID: followerID, UserID: userID})
+	})
+}
+
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+	return following, err
+}
+
+// GetFollowerIDs returns the IDs of the followers of userID that haven't
+// muted them, the users whose feed a new post of userID lands in.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `
+	SELECT f.follower_id FROM followers f
+	WHERE f.user_id = $1 AND NOT ` + mutedClause("f.user_id", "f.follower_id")
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-	return err
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
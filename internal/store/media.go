@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidAttachment = errors.New("attachments must be your own unattached uploads")
+
+// Media is an uploaded image, attached to at most one post. StorageKey and
+// ThumbnailKey name its objects in the object store.
+type Media struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `json:"user_id"`
+	PostID       *int64 `json:"post_id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AltText      string `json:"alt_text"`
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// mediaJSON scans the JSON array built by attachmentsColumn.
+type mediaJSON []Media
+
+func (m *mediaJSON) Scan(src any) error {
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("attachments: expected a JSON array")
+	}
+	return json.Unmarshal(data, (*[]Media)(m))
+}
+
+// attachmentsColumn is a select expression returning the media attached to
+// the post aliased as postAlias as a JSON array, in post order.
+func attachmentsColumn(postAlias string) string {
+	return fmt.Sprintf(`(
+		SELECT COALESCE(json_agg(json_build_object(
+			'id', md.id, 'user_id', md.user_id, 'post_id', md.post_id, 'url', md.url,
+			'thumbnail_url', md.thumbnail_url, 'content_type', md.content_type, 'size', md.size,
+			'width', md.width, 'height', md.height, 'alt_text', md.alt_text, 'created_at', md.created_at
+		) ORDER BY md.position), '[]')
+		FROM media md
+		WHERE md.post_id = %s.id
+	)`, postAlias)
+}
+
+// attachMedia attaches the uploads ids of userID to the new post postID, in
+// the order given. Each one has to belong to userID and never have been
+// attached, to this post or another, otherwise ErrInvalidAttachment is
+// returned. It returns the attached media.
+func attachMedia(ctx context.Context, tx *sql.Tx, postID, userID int64, ids []int64) ([]Media, error) {
+	query := `
+	UPDATE media SET post_id = $1, position = array_position($3::bigint[], id), attached_at = NOW()
+	WHERE id = ANY($3) AND user_id = $2 AND post_id IS NULL AND attached_at IS NULL
+	RETURNING id, user_id, post_id, url, thumbnail_url, content_type, size, width, height, alt_text,
+		storage_key, thumbnail_key, created_at, position
+	`
+
+	rows, err := tx.QueryContext(ctx, query, postID, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attached := make([]Media, len(ids))
+	n := 0
+	for rows.Next() {
+		var m Media
+		var position int
+		if err := rows.Scan(
+			&m.ID, &m.UserID, &m.PostID, &m.URL, &m.ThumbnailURL, &m.ContentType, &m.Size, &m.Width, &m.Height,
+			&m.AltText, &m.StorageKey, &m.ThumbnailKey, &m.CreatedAt, &position,
+		); err != nil {
+			return nil, err
+		}
+		attached[position-1] = m
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if n != len(ids) {
+		return nil, ErrInvalidAttachment
+	}
+
+	return attached, nil
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+func (s *MediaStore) Create(ctx context.Context, m *Media) error {
+	query := `
+	INSERT INTO media (user_id, storage_key, thumbnail_key, url, thumbnail_url, content_type, size, width, height, alt_text)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx, query, m.UserID, m.StorageKey, m.ThumbnailKey, m.URL, m.ThumbnailURL, m.ContentType, m.Size,
+		m.Width, m.Height, m.AltText,
+	).Scan(&m.ID, &m.CreatedAt)
+}
+
+func (s *MediaStore) GetByID(ctx context.Context, id, userID int64) (*Media, error) {
+	query := `
+	SELECT id, user_id, post_id, url, thumbnail_url, content_type, size, width, height, alt_text,
+		storage_key, thumbnail_key, created_at
+	FROM media WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var m Media
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&m.ID, &m.UserID, &m.PostID, &m.URL, &m.ThumbnailURL, &m.ContentType, &m.Size, &m.Width, &m.Height,
+		&m.AltText, &m.StorageKey, &m.ThumbnailKey, &m.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+// UpdateAltText sets the alt text of a media of userID, attached or not.
+func (s *MediaStore) UpdateAltText(ctx context.Context, id, userID int64, altText string) error {
+	query := `UPDATE media SET alt_text = $1 WHERE id = $2 AND user_id = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, altText, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes an unattached upload of userID. Attached media go away with
+// their post.
+func (s *MediaStore) Delete(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM media WHERE id = $1 AND user_id = $2 AND post_id IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeOrphans deletes up to limit media that are no longer attached, their
+// post having been purged, or that were never attached and uploaded before
+// before. It returns them so their objects can be deleted.
+func (s *MediaStore) PurgeOrphans(ctx context.Context, before time.Time, limit int) ([]Media, error) {
+	query := `
+	DELETE FROM media WHERE id IN (
+		SELECT id FROM media
+		WHERE post_id IS NULL AND (attached_at IS NOT NULL OR created_at < $1)
+		ORDER BY id
+		LIMIT $2
+	)
+	RETURNING id, user_id, storage_key, thumbnail_key
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purged := []Media{}
+	for rows.Next() {
+		var m Media
+		if err := rows.Scan(&m.ID, &m.UserID, &m.StorageKey, &m.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		purged = append(purged, m)
+	}
+
+	return purged, rows.Err()
+}
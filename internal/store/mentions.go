@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Mention is a user mentioned in a post or comment. Start and End are the
+// character offsets of the @username in the content, End excluded.
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// mentionsJSON scans the JSON array built by mentionsColumn.
+type mentionsJSON []Mention
+
+func (m *mentionsJSON) Scan(src any) error {
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("mentions: expected a JSON array")
+	}
+	return json.Unmarshal(data, (*[]Mention)(m))
+}
+
+// mentionsColumn is a select expression returning the mentions of the row
+// aliased as alias as a JSON array, column being post_id or comment_id.
+func mentionsColumn(column, alias string) string {
+	return fmt.Sprintf(`(
+		SELECT COALESCE(json_agg(json_build_object(
+			'user_id', m.user_id, 'username', mu.username, 'start', m.start_offset, 'end', m.end_offset
+		) ORDER BY m.start_offset), '[]')
+		FROM mentions m JOIN users mu ON mu.id = m.user_id
+		WHERE m.%s = %s.id
+	)`, column, alias)
+}
+
+// replaceMentions stores mentions as the mentions of the post or comment id,
+// column being post_id or comment_id, replacing the previous ones. Usernames
+// that don't belong to an active user, or to one that has a block with
+// authorID, are dropped. It returns the mentions kept with their user IDs.
+func replaceMentions(ctx context.Context, tx *sql.Tx, column string, id, authorID int64, mentions []Mention) ([]Mention, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM mentions WHERE %s = $1`, column)
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return nil, err
+	}
+
+	if len(mentions) == 0 {
+		return []Mention{}, nil
+	}
+
+	usernames := make([]string, len(mentions))
+	starts := make([]int64, len(mentions))
+	ends := make([]int64, len(mentions))
+	for i, m := range mentions {
+		usernames[i], starts[i], ends[i] = m.Username, int64(m.Start), int64(m.End)
+	}
+
+	query = fmt.Sprintf(`
+	INSERT INTO mentions (%s, user_id, start_offset, end_offset)
+	SELECT $1, u.id, m.start_offset, m.end_offset
+	FROM unnest($2::text[], $3::int[], $4::int[]) AS m(username, start_offset, end_offset)
+	JOIN users u ON u.username = m.username
+	WHERE u.is_active AND NOT %s
+	ORDER BY m.start_offset
+	RETURNING user_id, start_offset, end_offset
+	`, column, blockedClause("u.id", "$5"))
+
+	rows, err := tx.QueryContext(ctx, query, id, pq.Array(usernames), pq.Array(starts), pq.Array(ends), authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byStart := make(map[int]string, len(mentions))
+	for _, m := range mentions {
+		byStart[m.Start] = m.Username
+	}
+
+	kept := []Mention{}
+	for rows.Next() {
+		var m Mention
+		if err := rows.Scan(&m.UserID, &m.Start, &m.End); err != nil {
+			return nil, err
+		}
+		m.Username = byStart[m.Start]
+		kept = append(kept, m)
+	}
+
+	return kept, rows.Err()
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Message struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderID       int64     `json:"sender_id"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	Sender         User      `json:"sender"`
+}
+
+type MessageStore struct {
+	db *sql.DB
+}
+
+// Create sends a message to a conversation the sender takes part in, moving
+// their read cursor past it. In a 1:1 conversation the other participant must
+// still be reachable by the sender, see reachableClause, unless they already
+// wrote in it themselves; otherwise ErrBlocked is returned. Blocks never stop
+// anyone from writing to a group, they only hide messages from the blocker.
+func (s *MessageStore) Create(ctx context.Context, m *Message) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		var isGroup bool
+		query := `
+		SELECT c.is_group FROM conversations c
+		JOIN conversation_participants cp ON cp.conversation_id = c.id
+		WHERE c.id = $1 AND cp.user_id = $2
+		FOR UPDATE OF c
+		`
+		if err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID).Scan(&isGroup); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if !isGroup {
+			var allowed bool
+			query := `
+			SELECT COALESCE(bool_and(
+				` + reachableClause("u", "$2") + ` OR (NOT ` + blockedClause("u.id", "$2") + ` AND EXISTS (
+					SELECT 1 FROM messages om WHERE om.conversation_id = $1 AND om.sender_id = u.id
+				))
+			), false)
+			FROM conversation_participants cp JOIN users u ON u.id = cp.user_id
+			WHERE cp.conversation_id = $1 AND cp.user_id <> $2
+			`
+			if err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID).Scan(&allowed); err != nil {
+				return err
+			}
+			if !allowed {
+				return ErrBlocked
+			}
+		}
+
+		query = `
+		INSERT INTO messages (conversation_id, sender_id, content)
+		VALUES ($1, $2, $3) RETURNING id, created_at
+		`
+		if err := tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID, m.Content).Scan(&m.ID, &m.CreatedAt); err != nil {
+			return err
+		}
+
+		query = `UPDATE conversations SET last_message_at = $2 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, m.ConversationID, m.CreatedAt); err != nil {
+			return err
+		}
+
+		query = `
+		UPDATE conversation_participants SET last_read_message_id = $3
+		WHERE conversation_id = $1 AND user_id = $2
+		`
+		_, err := tx.ExecContext(ctx, query, m.ConversationID, m.SenderID, m.ID)
+		return err
+	})
+}
+
+// GetByConversationID returns up to limit messages of a conversation, newest
+// first, older than the message before when it isn't 0. Messages of users
+// that have a block with viewerID are left out.
+func (s *MessageStore) GetByConversationID(ctx context.Context, conversationID, viewerID, before int64, limit int) ([]Message, error) {
+	query := `
+	SELECT m.id, m.conversation_id, m.sender_id, m.content, m.created_at, u.id, u.username
+	FROM messages m
+	JOIN users u ON u.id = m.sender_id
+	WHERE m.conversation_id = $1 AND ($3::bigint = 0 OR m.id < $3) AND NOT ` + blockedClause("m.sender_id", "$2") + `
+	ORDER BY m.id DESC
+	LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, conversationID, viewerID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Content, &m.CreatedAt, &m.Sender.ID, &m.Sender.UserName)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type Mute struct {
+	MuterID   int64  `json:"muter_id"`
+	MutedID   int64  `json:"muted_id"`
+	CreatedAt string `json:"created_at"`
+	User      User   `json:"user"`
+}
+
+type MuteStore struct {
+	db *sql.DB
+}
+
+// mutedClause is a SQL condition that is true when the user bound to
+// viewerParam has muted the user in column userCol. Mutes only apply to the
+// muter's own feeds, unlike blocks.
+func mutedClause(userCol, viewerParam string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM user_mutes um WHERE um.muter_id = %[2]s AND um.muted_id = %[1]s
+	)`, userCol, viewerParam)
+}
+
+// Mute hides the posts of mutedID from muterID, failing with ErrNotFound when
+// mutedID doesn't exist.
+func (s *MuteStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	query := `INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505":
+				return ErrConflict
+			case "23503":
+				return ErrNotFound
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *MuteStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (s *MuteStore) GetMuted(ctx context.Context, muterID int64) ([]Mute, error) {
+	query := `
+	SELECT um.muter_id, um.muted_id, um.created_at, u.id, u.username
+	FROM user_mutes um
+	JOIN users u ON u.id = um.muted_id
+	WHERE um.muter_id = $1
+	ORDER BY um.created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, muterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := []Mute{}
+
+	for rows.Next() {
+		var m Mute
+
+		if err := rows.Scan(&m.MuterID, &m.MutedID, &m.CreatedAt, &m.User.ID, &m.User.UserName); err != nil {
+			return nil, err
+		}
+
+		mutes = append(mutes, m)
+	}
+
+	return mutes, rows.Err()
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationComment       = "comment"
+	NotificationReply         = "reply"
+	NotificationMention       = "mention"
+	NotificationRepost        = "repost"
+	NotificationQuote         = "quote"
+	NotificationReaction      = "reaction"
+)
+
+// notificationActorsShown is how many of the latest actors a group lists.
+const notificationActorsShown = 3
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	ActorID   int64      `json:"actor_id"`
+	Type      string     `json:"type"`
+	PostID    *int64     `json:"post_id"`
+	CommentID *int64     `json:"comment_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// NotificationGroup gathers the notifications of one type about the same
+// target, like every comment left on a post, that are all read or all unread.
+// ID is the latest of them and identifies the group when marking it read.
+// CommentID is only set for replies, to the comment replied to.
+type NotificationGroup struct {
+	ID         int64  `json:"id"`
+	Type       string `json:"type"`
+	PostID     *int64 `json:"post_id"`
+	CommentID  *int64 `json:"comment_id"`
+	Count      int    `json:"count"`
+	ActorCount int    `json:"actor_count"`
+	Actors     []User `json:"actors"`
+	Unread     bool   `json:"unread"`
+	LatestAt   string `json:"latest_at"`
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// notificationVisibleClause is a SQL condition that is true when the
+// notification aliased as n still makes sense to its recipient: the actor has
+// no block with them and isn't muted, and the post and comment it is about
+// weren't deleted or hidden from them since. It expects the actor joined as
+// a, the post as p with its author as pu, and the comment as c.
+func notificationVisibleClause() string {
+	return `
+		NOT ` + blockedClause("n.actor_id", "n.user_id") + ` AND
+		NOT ` + mutedClause("n.actor_id", "n.user_id") + ` AND
+		a.is_active AND
+		(n.post_id IS NULL OR (p.deleted_at IS NULL AND ` + postVisibleClause("p", "pu", "n.user_id") + `)) AND
+		(n.comment_id IS NULL OR c.deleted_at IS NULL)
+	`
+}
+
+// notificationGroupComment is the comment a notification aliased as n is
+// grouped under, given parentCol holding the parent of its comment. Replies
+// group under the comment replied to, while comments and mentions group under
+// their post alone, whichever comment triggered them.
+func notificationGroupComment(parentCol string) string {
+	return fmt.Sprintf(`CASE WHEN n.type = '%s' THEN %s END`, NotificationReply, parentCol)
+}
+
+const notificationJoins = `
+	JOIN users a ON a.id = n.actor_id
+	LEFT JOIN posts p ON p.id = n.post_id
+	LEFT JOIN users pu ON pu.id = p.user_id
+	LEFT JOIN comments c ON c.id = n.comment_id
+`
+
+func (s *NotificationStore) Create(ctx context.Context, n *Notification) error {
+	query := `
+	INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx, query, n.UserID, n.ActorID, n.Type, n.PostID, n.CommentID,
+	).Scan(&n.ID, &n.CreatedAt)
+}
+
+// GetByUserID returns the notification groups of userID, the latest first,
+// only the unread ones if unreadOnly is set.
+func (s *NotificationStore) GetByUserID(ctx context.Context, userID int64, unreadOnly bool, limit, offset int) ([]NotificationGroup, error) {
+	query := `
+	SELECT
+		MAX(n.id),
+		n.type,
+		n.post_id,
+		` + notificationGroupComment("c.parent_id") + `,
+		COUNT(*),
+		COUNT(DISTINCT n.actor_id),
+		(array_agg(n.actor_id ORDER BY n.id DESC))[1:10],
+		(array_agg(a.username ORDER BY n.id DESC))[1:10],
+		n.read_at IS NULL AS unread,
+		MAX(n.created_at) AS latest_at
+	FROM notifications n
+	` + notificationJoins + `
+	WHERE n.user_id = $1 AND (NOT $4 OR n.read_at IS NULL) AND ` + notificationVisibleClause() + `
+	GROUP BY n.type, n.post_id, ` + notificationGroupComment("c.parent_id") + `, n.read_at IS NULL
+	ORDER BY latest_at DESC, MAX(n.id) DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []NotificationGroup{}
+	for rows.Next() {
+		var g NotificationGroup
+		var actorIDs []int64
+		var usernames []string
+
+		err := rows.Scan(
+			&g.ID,
+			&g.Type,
+			&g.PostID,
+			&g.CommentID,
+			&g.Count,
+			&g.ActorCount,
+			pq.Array(&actorIDs),
+			pq.Array(&usernames),
+			&g.Unread,
+			&g.LatestAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// the same actor can appear several times, like when commenting twice
+		g.Actors = []User{}
+		seen := map[int64]bool{}
+		for i, id := range actorIDs {
+			if seen[id] || len(g.Actors) == notificationActorsShown {
+				continue
+			}
+			seen[id] = true
+			g.Actors = append(g.Actors, User{ID: id, UserName: usernames[i]})
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// CountUnread returns how many unread notifications userID has, counting
+// every notification of a group.
+func (s *NotificationStore) CountUnread(ctx context.Context, userID int64) (int, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM notifications n
+	` + notificationJoins + `
+	WHERE n.user_id = $1 AND n.read_at IS NULL AND ` + notificationVisibleClause()
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// IsVisible reports whether notification id still makes sense to its
+// recipient, see notificationVisibleClause.
+func (s *NotificationStore) IsVisible(ctx context.Context, id int64) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM notifications n
+		` + notificationJoins + `
+		WHERE n.id = $1 AND ` + notificationVisibleClause() + `
+	)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var visible bool
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&visible)
+	return visible, err
+}
+
+// MarkRead marks notification id of userID as read, along with the older
+// unread notifications grouped with it.
+func (s *NotificationStore) MarkRead(ctx context.Context, userID, id int64) error {
+	query := `
+	WITH target AS (
+		SELECT n.type, n.post_id, ` + notificationGroupComment("c.parent_id") + ` AS comment_id
+		FROM notifications n
+		LEFT JOIN comments c ON c.id = n.comment_id
+		WHERE n.id = $2 AND n.user_id = $1
+	), marked AS (
+		UPDATE notifications n SET read_at = NOW()
+		FROM target t
+		WHERE n.user_id = $1 AND n.id <= $2 AND n.read_at IS NULL AND
+			n.type = t.type AND
+			n.post_id IS NOT DISTINCT FROM t.post_id AND
+			` + notificationGroupComment("(SELECT parent_id FROM comments WHERE id = n.comment_id)") + `
+				IS NOT DISTINCT FROM t.comment_id
+	)
+	SELECT EXISTS (SELECT 1 FROM target)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var found bool
+	if err := s.db.QueryRowContext(ctx, query, userID, id).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every notification of userID as read.
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) error {
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
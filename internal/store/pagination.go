@@ -1,10 +1,13 @@
 package store
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type PaginatedFeedQuery struct {
@@ -15,6 +18,8 @@ type PaginatedFeedQuery struct {
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	// Blend fills the home feed with explore posts once the followed ones run out.
+	Blend bool `json:"blend"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -65,9 +70,40 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 
 	}
 
+	blend := qs.Get("blend")
+	if blend != "" {
+		b, err := strconv.ParseBool(blend)
+		if err != nil {
+			return fq, err
+		}
+		fq.Blend = b
+	}
+
 	return fq, nil
 }
 
+// filterClause is the SQL condition applying the search, tags and time window
+// to the post aliased as postAlias. Its values are bound from $firstParam
+// onwards, in the order returned by filterArgs.
+func (fq PaginatedFeedQuery) filterClause(postAlias string, firstParam int) string {
+	return fmt.Sprintf(`(%[1]s.title ILIKE '%%' || $%[2]d || '%%' OR %[1]s.content ILIKE '%%' || $%[2]d || '%%') AND
+		(coalesce(cardinality($%[3]d::varchar[]), 0) = 0 OR %[1]s.tags @> $%[3]d) AND
+		($%[4]d::timestamptz IS NULL OR %[1]s.created_at >= $%[4]d) AND
+		($%[5]d::timestamptz IS NULL OR %[1]s.created_at <= $%[5]d)`,
+		postAlias, firstParam, firstParam+1, firstParam+2, firstParam+3)
+}
+
+func (fq PaginatedFeedQuery) filterArgs() []any {
+	return []any{fq.Search, pq.Array(fq.Tags), nullIfEmpty(fq.Since), nullIfEmpty(fq.Until)}
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func parseTime(s string) string {
 	t, err := time.Parse(time.DateTime, s)
 	if err != nil {
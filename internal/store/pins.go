@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrPinLimit = errors.New("pinned posts limit reached")
+
+type PinStore struct {
+	db *sql.DB
+}
+
+// Pin adds postID at the end of the pinned posts on userID's profile, failing
+// with ErrPinLimit once limit posts are pinned. The user row is locked so
+// concurrent pins can't go over the limit.
+func (s *PinStore) Pin(ctx context.Context, userID, postID int64, limit int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+
+		var pinned bool
+		var count, last int
+		query := `
+		SELECT COALESCE(bool_or(pp.post_id = $2), false),
+			COUNT(*) FILTER (WHERE p.deleted_at IS NULL),
+			COALESCE(MAX(pp.position), 0)
+		FROM pinned_posts pp
+		JOIN posts p ON p.id = pp.post_id
+		WHERE pp.user_id = $1
+		`
+		if err := tx.QueryRowContext(ctx, query, userID, postID).Scan(&pinned, &count, &last); err != nil {
+			return err
+		}
+
+		if pinned {
+			return ErrConflict
+		}
+		if count >= limit {
+			return fmt.Errorf("%w: at most %d posts can be pinned", ErrPinLimit, limit)
+		}
+
+		query = `INSERT INTO pinned_posts (user_id, post_id, position) VALUES ($1, $2, $3)`
+		_, err := tx.ExecContext(ctx, query, userID, postID, last+1)
+		return err
+	})
+}
+
+func (s *PinStore) Unpin(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM pinned_posts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Poll results settings, deciding when voters see the counts. Authors always
+// see them, and everyone does once the poll is closed.
+const (
+	PollResultsAlways     = "always"
+	PollResultsAfterVote  = "after_vote"
+	PollResultsAfterClose = "after_close"
+)
+
+var (
+	ErrPollClosed        = errors.New("poll is closed")
+	ErrInvalidPollChoice = errors.New("invalid poll choice")
+)
+
+// Poll is attached to a post. Vote counts are null while ResultsHidden.
+type Poll struct {
+	Multiple          bool         `json:"multiple"`
+	ResultsVisibility string       `json:"results_visibility"`
+	ClosesAt          *time.Time   `json:"closes_at"`
+	Closed            bool         `json:"closed"`
+	Voted             bool         `json:"voted"`
+	Choices           []int64      `json:"choices"`
+	ResultsHidden     bool         `json:"results_hidden"`
+	TotalVoters       *int         `json:"total_voters"`
+	Options           []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	ID    int64  `json:"id"`
+	Label string `json:"label"`
+	Votes *int   `json:"votes"`
+}
+
+// pollJSON scans the JSON object built by pollColumn.
+type pollJSON struct{ p **Poll }
+
+func (j pollJSON) Scan(src any) error {
+	if src == nil {
+		*j.p = nil
+		return nil
+	}
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("poll: expected a JSON object")
+	}
+	return json.Unmarshal(data, j.p)
+}
+
+// pollColumn is a select expression returning the poll of the post aliased as
+// postAlias as a JSON object, or null, as seen by the user bound to
+// viewerParam: their choices, and the counts only when they may see them.
+func pollColumn(postAlias, viewerParam string) string {
+	return fmt.Sprintf(`(
+		SELECT json_build_object(
+			'multiple', pl.multiple,
+			'results_visibility', pl.results_visibility,
+			'closes_at', pl.closes_at,
+			'closed', st.closed,
+			'voted', st.voted,
+			'choices', COALESCE((
+				SELECT json_agg(pv.option_id) FROM poll_votes pv
+				WHERE pv.post_id = pl.post_id AND pv.user_id = %[2]s
+			), '[]'),
+			'results_hidden', NOT st.visible,
+			'total_voters', CASE WHEN st.visible THEN (
+				SELECT COUNT(*) FROM poll_ballots pb WHERE pb.post_id = pl.post_id
+			) END,
+			'options', (
+				SELECT json_agg(json_build_object(
+					'id', po.id, 'label', po.label,
+					'votes', CASE WHEN st.visible THEN (
+						SELECT COUNT(*) FROM poll_votes pv WHERE pv.option_id = po.id
+					) END
+				) ORDER BY po.position)
+				FROM poll_options po WHERE po.post_id = pl.post_id
+			)
+		)
+		FROM polls pl
+		CROSS JOIN LATERAL (
+			SELECT
+				pl.closes_at IS NOT NULL AND pl.closes_at <= NOW() AS closed,
+				EXISTS (SELECT 1 FROM poll_ballots pb WHERE pb.post_id = pl.post_id AND pb.user_id = %[2]s) AS voted
+		) s0
+		CROSS JOIN LATERAL (
+			SELECT s0.closed, s0.voted,
+				pl.results_visibility = 'always' OR s0.closed OR %[1]s.user_id = %[2]s
+				OR (pl.results_visibility = 'after_vote' AND s0.voted) AS visible
+		) st
+		WHERE pl.post_id = %[1]s.id
+	)`, postAlias, viewerParam)
+}
+
+// createPoll stores the poll of a new post, filling in the option IDs.
+func createPoll(ctx context.Context, tx *sql.Tx, postID int64, poll *Poll) error {
+	if poll.ResultsVisibility == "" {
+		poll.ResultsVisibility = PollResultsAlways
+	}
+
+	query := `INSERT INTO polls (post_id, multiple, results_visibility, closes_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, postID, poll.Multiple, poll.ResultsVisibility, poll.ClosesAt); err != nil {
+		return err
+	}
+
+	labels := make([]string, len(poll.Options))
+	for i, o := range poll.Options {
+		labels[i] = o.Label
+	}
+
+	query = `
+	INSERT INTO poll_options (post_id, position, label)
+	SELECT $1, o.position, o.label FROM unnest($2::varchar[]) WITH ORDINALITY AS o(label, position)
+	ORDER BY o.position
+	RETURNING id, position
+	`
+	rows, err := tx.QueryContext(ctx, query, postID, pq.Array(labels))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	zero := 0
+	for rows.Next() {
+		var id, position int64
+		if err := rows.Scan(&id, &position); err != nil {
+			return err
+		}
+		poll.Options[position-1].ID = id
+		poll.Options[position-1].Votes = &zero
+	}
+	poll.TotalVoters = &zero
+	poll.Choices = []int64{}
+
+	return rows.Err()
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+// Get returns the poll of a post as seen by viewerID, see pollColumn.
+func (s *PollStore) Get(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	query := `SELECT ` + pollColumn("p", "$2") + ` FROM posts p WHERE p.id = $1 AND p.deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var poll *Poll
+	if err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan(pollJSON{&poll}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	if poll == nil {
+		return nil, ErrNotFound
+	}
+
+	return poll, nil
+}
+
+// Vote casts the ballot of userID. Single choice polls take exactly one
+// option, multiple choice ones at least one, all from the poll. Voting twice
+// is reported as ErrConflict, voting after closes_at as ErrPollClosed.
+func (s *PollStore) Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		var multiple, closed bool
+		var valid int
+		query := `
+		SELECT pl.multiple, pl.closes_at IS NOT NULL AND pl.closes_at <= NOW(),
+			(SELECT COUNT(*) FROM poll_options po WHERE po.post_id = pl.post_id AND po.id = ANY($2))
+		FROM polls pl WHERE pl.post_id = $1
+		`
+		err := tx.QueryRowContext(ctx, query, postID, pq.Array(optionIDs)).Scan(&multiple, &closed, &valid)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if closed {
+			return ErrPollClosed
+		}
+		if valid != len(optionIDs) || (!multiple && len(optionIDs) != 1) {
+			return ErrInvalidPollChoice
+		}
+
+		query = `INSERT INTO poll_ballots (post_id, user_id) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, postID, userID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		query = `INSERT INTO poll_votes (post_id, user_id, option_id) SELECT $1, $2, unnest($3::bigint[])`
+		_, err = tx.ExecContext(ctx, query, postID, userID, pq.Array(optionIDs))
+		return err
+	})
+}
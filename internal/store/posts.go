@@ -4,131 +4,608 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"social/internal/events"
+	"social/internal/markdown"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Post struct {
-	ID        int64
-	Content   string `json:"content"`
-	Title     string `json:"title"`
-	UserID    int64
-	Tags      []string `json:"tags"`
-	CreatedAt string   `json:"created_at"`
-	UpdatedAt string
-	Version   int        `json:"version"`
-	Comments  []*Comment `json:"comments"`
-	User      User       `json:"user"`
+	ID      int64
+	Content string `json:"content"`
+	// ContentHTML is Content rendered from Markdown, set by the store on write
+	ContentHTML string `json:"content_html"`
+	Title       string `json:"title"`
+	UserID      int64
+	Tags        []string `json:"tags"`
+	// ExplicitTags are the tags set by the author, Tags adds the hashtags of
+	// the content to them
+	ExplicitTags []string `json:"-"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string
+	Version      int        `json:"version"`
+	Visibility   string     `json:"visibility"`
+	Pinned       bool       `json:"pinned"`
+	Status       string     `json:"status"`
+	PublishAt    *time.Time `json:"publish_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	Mentions     []Mention  `json:"mentions"`
+	// QuotedPost is null when the quoted post was deleted or can't be read
+	// anymore, QuotedPostID is kept
+	QuotedPostID *int64      `json:"quoted_post_id"`
+	QuotedPost   *QuotedPost `json:"quoted_post"`
+	Poll         *Poll       `json:"poll"`
+	Attachments  []Media     `json:"attachments"`
+	Comments     []*Comment  `json:"comments"`
+	User         User        `json:"user"`
 }
 
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type PostWithMetaData struct {
 	Post
-	CommentCount int `json:"comments_count"`
+	CommentCount int  `json:"comments_count"`
+	Bookmarked   bool `json:"bookmarked"`
+	RepostCount  int  `json:"reposts_count"`
+	Reposted     bool `json:"reposted"`
+	// RepostedBy is set when the post is in the feed because a followed user
+	// reposted it
+	RepostedBy *User      `json:"reposted_by,omitempty"`
+	RepostedAt *time.Time `json:"reposted_at,omitempty"`
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
+// postWithMetaDataColumns is the select list read by scanPostsWithMetaData.
+// It expects posts aliased as p and their author as u, and flags the posts
+// bookmarked and reposted by the user bound to viewerParam, which quoted posts
+// and polls are embedded for.
+func postWithMetaDataColumns(viewerParam string) string {
+	return `
+	p.id,
+	p.user_id,
+	p.title,
+	p.content,
+	p.content_html,
+	p.created_at,
+	p.version,
+	p.tags,
+	p.visibility,
+	p.status,
+	p.publish_at,
+	p.deleted_at,
+	u.username,
+	EXISTS (SELECT 1 FROM pinned_posts pp WHERE pp.post_id = p.id) AS pinned,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count,
+	` + mentionsColumn("post_id", "p") + ` AS mentions,
+	EXISTS (SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = ` + viewerParam + `) AS bookmarked,
+	p.quoted_post_id,
+	` + quotedPostColumn("p", viewerParam) + ` AS quoted_post,
+	(SELECT COUNT(*) FROM reposts rp WHERE rp.post_id = p.id) AS reposts_count,
+	EXISTS (SELECT 1 FROM reposts rp WHERE rp.post_id = p.id AND rp.user_id = ` + viewerParam + `) AS reposted,
+	` + pollColumn("p", viewerParam) + ` AS poll,
+	` + attachmentsColumn("p") + ` AS attachments
+`
+}
+
+func scanPostsWithMetaData(rows *sql.Rows) ([]PostWithMetaData, error) {
+	posts := []PostWithMetaData{}
+
+	for rows.Next() {
+		var p PostWithMetaData
+
+		if err := rows.Scan(postWithMetaDataDest(&p)...); err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// postWithMetaDataDest returns the scan destinations of postWithMetaDataColumns.
+func postWithMetaDataDest(p *PostWithMetaData) []any {
+	return []any{
+		&p.ID,
+		&p.UserID,
+		&p.Title,
+		&p.Content,
+		&p.ContentHTML,
+		&p.CreatedAt,
+		&p.Version,
+		pq.Array(&p.Tags),
+		&p.Visibility,
+		&p.Status,
+		&p.PublishAt,
+		&p.DeletedAt,
+		&p.User.UserName,
+		&p.Pinned,
+		&p.CommentCount,
+		(*mentionsJSON)(&p.Mentions),
+		&p.Bookmarked,
+		&p.QuotedPostID,
+		quotedPostJSON{&p.QuotedPost},
+		&p.RepostCount,
+		&p.Reposted,
+		pollJSON{&p.Poll},
+		(*mediaJSON)(&p.Attachments),
+	}
+}
+
+// GetUserFeed returns the posts of userID and of the users they follow, and
+// the posts those users reposted, attributed to the reposter. A post shows up
+// once however many times it was reposted, at its first appearance in the
+// requested order. Reposts of posts that were deleted or can't be read by
+// userID anymore are dropped. With fq.Blend, explore posts are listed after
+// them, so the feed keeps going for users who follow few people.
+//
+// Each branch is filtered and cut to the page end on its own, so the query
+// only sorts what can make it into the page however big the tables grow.
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	followed := `(p.user_id = $1 OR EXISTS (
+		SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+	))`
+
+	// the conditions every post of the feed meets, whichever branch lists it
+	readable := `
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		` + postVisibleClause("p", "u", "$1") + ` AND
+		NOT ` + mutedClause("p.user_id", "$1") + ` AND
+		NOT ` + feedFilterClause("p", "$1") + ` AND
+		` + fq.filterClause("p", 4)
 
 	query := `
-        SELECT 
-            p.id,
-            p.user_id,
-            p.title,
-            p.content,
-            p.created_at,
-            p.version,
-            p.tags,
-            u.username,
-            COUNT(c.id) AS comments_count
-        FROM posts p
-        LEFT JOIN comments c ON c.post_id = p.id
-		LEFT JOIN users u ON p.user_id = u.id
-        JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
-        
-        WHERE 
-		f.user_id = $1 AND 
-		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-		(p.tags @> $5 OR $5 = '{}')
-        GROUP BY p.id, u.username
-        ORDER BY p.created_at ` + fq.Sort + `
-		LIMIT $2 OFFSET $3
-    `
+	WITH posted AS (
+		SELECT p.id AS post_id, NULL::bigint AS reposter_id, NULL::timestamptz AS reposted_at,
+			p.created_at AS sort_at, ` + followed + ` AS followed
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE
+			(` + followed + ` OR ($8 AND ` + discoverableClause("p", "u") + `)) AND
+			` + readable + `
+		ORDER BY followed DESC, sort_at ` + fq.Sort + `, p.id ` + fq.Sort + `
+		LIMIT $2 + $3
+	), reposted AS (
+		SELECT * FROM (
+			SELECT DISTINCT ON (r.post_id) r.post_id, r.user_id AS reposter_id, r.created_at AS reposted_at,
+				r.created_at AS sort_at, true AS followed
+			FROM reposts r
+			JOIN posts p ON p.id = r.post_id
+			JOIN users u ON p.user_id = u.id
+			WHERE
+				(r.user_id = $1 OR EXISTS (
+					SELECT 1 FROM followers f WHERE f.user_id = r.user_id AND f.follower_id = $1
+				)) AND
+				NOT ` + blockedClause("r.user_id", "$1") + ` AND
+				NOT ` + mutedClause("r.user_id", "$1") + ` AND
+				` + readable + `
+			ORDER BY r.post_id, r.created_at ` + fq.Sort + `
+		) first_reposts
+		ORDER BY sort_at ` + fq.Sort + `, post_id ` + fq.Sort + `
+		LIMIT $2 + $3
+	), feed AS (
+		-- the entry kept for a post is the one coming first in the page order
+		SELECT DISTINCT ON (post_id) * FROM (
+			SELECT * FROM posted UNION ALL SELECT * FROM reposted
+		) entries
+		ORDER BY post_id, followed DESC, sort_at ` + fq.Sort + `
+	)
+	SELECT ` + postWithMetaDataColumns("$1") + `, rep.id, rep.username, e.reposted_at
+	FROM feed e
+	JOIN posts p ON p.id = e.post_id
+	JOIN users u ON p.user_id = u.id
+	LEFT JOIN users rep ON rep.id = e.reposter_id
+	ORDER BY e.followed DESC, e.sort_at ` + fq.Sort + `, p.id ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	args := append([]any{userID, fq.Limit, fq.Offset}, fq.filterArgs()...)
+	args = append(args, fq.Blend)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []PostWithMetaData
+	posts := []PostWithMetaData{}
 
 	for rows.Next() {
-		var p PostWithMetaData
-
-		err := rows.Scan(
-			&p.ID,
-			&p.UserID,
-			&p.Title,
-			&p.Content,
-			&p.CreatedAt,
-			&p.Version,
-			pq.Array(&p.Tags),
-			&p.User.UserName,
-			&p.CommentCount,
+		var (
+			p            PostWithMetaData
+			reposterID   sql.NullInt64
+			reposterName sql.NullString
 		)
-		if err != nil {
+
+		dest := append(postWithMetaDataDest(&p), &reposterID, &reposterName, &p.RepostedAt)
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		p.User.ID = p.UserID
+		if reposterID.Valid {
+			p.RepostedBy = &User{ID: reposterID.Int64, UserName: reposterName.String}
+		}
 
-		feed = append(feed, p)
+		posts = append(posts, p)
 	}
 
-	return feed, nil
+	return posts, rows.Err()
 }
 
-func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `INSERT INTO posts(content,title,user_id,tags)
-	values ($1, $2, $3,$4) RETURNING id, created_at, updated_at`
+// GetPublicTimeline returns the discoverable posts of every user, leaving out
+// authors viewerID blocked, was blocked by or muted, and posts matching the
+// viewer's feed filters.
+func (s *PostStore) GetPublicTimeline(ctx context.Context, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$1") + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		` + discoverableClause("p", "u") + ` AND
+		NOT ` + blockedClause("p.user_id", "$1") + ` AND
+		NOT ` + mutedClause("p.user_id", "$1") + ` AND
+		NOT ` + feedFilterClause("p", "$1") + ` AND
+		` + fq.filterClause("p", 4) + `
+	ORDER BY p.created_at ` + fq.Sort + `
+	LIMIT $2 OFFSET $3
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content, post.Title, post.UserID, pq.Array(post.Tags),
-	).Scan(
-		&post.ID, &post.CreatedAt, &post.UpdatedAt,
-	)
+	args := append([]any{viewerID, fq.Limit, fq.Offset}, fq.filterArgs()...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostsWithMetaData(rows)
+}
+
+// GetUserPosts returns the posts written by authorID that viewerID is allowed
+// to read, the author's pinned posts first in pin order.
+func (s *PostStore) GetUserPosts(ctx context.Context, authorID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$2") + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	LEFT JOIN pinned_posts pin ON pin.post_id = p.id
+	WHERE
+		p.user_id = $1 AND
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		` + postVisibleClause("p", "u", "$2") + ` AND
+		` + fq.filterClause("p", 5) + `
+	ORDER BY pin.position ASC NULLS LAST, p.created_at ` + fq.Sort + `
+	LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	args := append([]any{authorID, viewerID, fq.Limit, fq.Offset}, fq.filterArgs()...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostsWithMetaData(rows)
+}
+
+// GetByTag returns the published posts tagged with tag that viewerID is
+// allowed to read, newest first by default. The containment test is served by
+// idx_posts_tags. Unlisted posts are only listed for their author.
+func (s *PostStore) GetByTag(ctx context.Context, tag string, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$2") + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE
+		p.tags @> ARRAY[$1]::varchar[] AND
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		(p.visibility <> 'unlisted' OR p.user_id = $2) AND
+		` + postVisibleClause("p", "u", "$2") + ` AND
+		` + fq.filterClause("p", 5) + `
+	ORDER BY p.created_at ` + fq.Sort + `
+	LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	args := append([]any{tag, viewerID, fq.Limit, fq.Offset}, fq.filterArgs()...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostsWithMetaData(rows)
+}
+
+// GetDrafts returns the draft and scheduled posts of userID, most recently edited first.
+func (s *PostStore) GetDrafts(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$1") + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.user_id = $1 AND p.status <> 'published' AND p.deleted_at IS NULL
+	ORDER BY p.updated_at DESC
+	LIMIT $2 OFFSET $3
+	`
 
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+
+	return scanPostsWithMetaData(rows)
+}
+
+// FilterFeedViewers returns the users of viewerIDs whose feed would show
+// postID, applying the visibility, mute and feed filter checks of GetUserFeed.
+// Live pushes use it so they don't reach users the feed would hide the post from.
+func (s *PostStore) FilterFeedViewers(ctx context.Context, postID int64, viewerIDs []int64) ([]int64, error) {
+	query := `
+	SELECT v.id
+	FROM unnest($2::bigint[]) AS v(id)
+	JOIN posts p ON p.id = $1
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		` + postVisibleClause("p", "u", "v.id") + ` AND
+		NOT ` + mutedClause("p.user_id", "v.id") + ` AND
+		NOT ` + feedFilterClause("p", "v.id") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID, pq.Array(viewerIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PublishDue publishes up to limit scheduled posts whose publish_at has passed
+// and returns their IDs. Rows are claimed with FOR UPDATE SKIP LOCKED, so
+// every API replica can run it concurrently and each post is published once.
+// Published posts lose their publish_at, which only scheduled posts carry, and
+// get a new version so cached copies and If-Match tags are invalidated. The
+// version they replace is snapshotted into post_revisions like in Update.
+func (s *PostStore) PublishDue(ctx context.Context, limit int) ([]int64, error) {
+	var ids []int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		WITH due AS (
+			SELECT id FROM posts
+			WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), revisions AS (
+			INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+			SELECT p.id, p.version, p.title, p.content, p.tags, p.updated_at
+			FROM posts p JOIN due ON due.id = p.id
+		)
+		UPDATE posts SET status = 'published', publish_at = NULL, created_at = NOW(), updated_at = NOW(),
+			version = version + 1
+		FROM due
+		WHERE posts.id = due.id
+		RETURNING posts.id, posts.user_id
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var published []events.PostEvent
+		for rows.Next() {
+			ev := events.PostEvent{Status: PostStatusPublished}
+			if err := rows.Scan(&ev.ID, &ev.UserID); err != nil {
+				return err
+			}
+			published = append(published, ev)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, ev := range published {
+			if err := events.Publish(ctx, tx, events.PostPublished, ev); err != nil {
+				return err
+			}
+			ids = append(ids, ev.ID)
+		}
+
+		return nil
+	})
+
+	return ids, err
+}
+
+// Create stores the post along with its mentions and its content rendered from
+// Markdown. post.Mentions only needs usernames and offsets, and is left with
+// the mentions that resolved to users. A quote post is only stored when the
+// original is shareable, see shareableClause, otherwise ErrNotShareable is
+// returned. post.Poll, when set, only needs its settings and option labels.
+// post.Attachments only needs the media IDs, and is filled in once they are
+// attached, see attachMedia.
+func (s *PostStore) Create(ctx context.Context, post *Post) error {
+	if post.Visibility == "" {
+		post.Visibility = PostVisibilityPublic
+	}
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		if post.QuotedPostID != nil {
+			query := `
+			SELECT ` + quotedPostObject + `
+			FROM posts q JOIN users qu ON qu.id = q.user_id
+			WHERE q.id = $1 AND ` + shareableClause("q", "qu", "$2")
+
+			err := tx.QueryRowContext(ctx, query, *post.QuotedPostID, post.UserID).Scan(quotedPostJSON{&post.QuotedPost})
+			if err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return ErrNotShareable
+				default:
+					return err
+				}
+			}
+		}
+
+		post.ContentHTML = markdown.Render(post.Content)
+
+		query := `INSERT INTO posts(content,title,user_id,tags,visibility,status,publish_at,quoted_post_id,content_html,
+			explicit_tags)
+		values ($1, $2, $3,$4,$5,$6,$7,$8,$9,$10) RETURNING id, created_at, updated_at`
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content, post.Title, post.UserID, pq.Array(post.Tags), post.Visibility, post.Status, post.PublishAt,
+			post.QuotedPostID, post.ContentHTML, pq.Array(post.ExplicitTags),
+		).Scan(
+			&post.ID, &post.CreatedAt, &post.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := replacePostTags(ctx, tx, post.ID, post.Tags); err != nil {
+			return err
+		}
+
+		post.Mentions, err = replaceMentions(ctx, tx, "post_id", post.ID, post.UserID, post.Mentions)
+		if err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			if err := createPoll(ctx, tx, post.ID, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		if len(post.Attachments) > 0 {
+			ids := make([]int64, len(post.Attachments))
+			for i, m := range post.Attachments {
+				ids[i] = m.ID
+			}
+			post.Attachments, err = attachMedia(ctx, tx, post.ID, post.UserID, ids)
+			if err != nil {
+				return err
+			}
+		}
+
+		ev := events.PostEvent{ID: post.ID, UserID: post.UserID, Status: post.Status}
+		if err := events.Publish(ctx, tx, events.PostCreated, ev); err != nil {
+			return err
+		}
+		if post.Status == PostStatusPublished {
+			return events.Publish(ctx, tx, events.PostPublished, ev)
+		}
+		return nil
+	})
 }
 
 func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
-	query := `SELECT id,user_id,title,content,created_at,updated_at,version,tags FROM posts WHERE id = $1`
+	return s.getPost(ctx, `p.id = $1 AND p.deleted_at IS NULL`, id)
+}
+
+// GetTrashedById returns a soft deleted post, which GetById no longer finds.
+func (s *PostStore) GetTrashedById(ctx context.Context, id int64) (*Post, error) {
+	return s.getPost(ctx, `p.id = $1 AND p.deleted_at IS NOT NULL`, id)
+}
+
+func (s *PostStore) getPost(ctx context.Context, where string, args ...any) (*Post, error) {
+	query := `
+	SELECT p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.updated_at, p.version, p.tags, p.visibility,
+		p.status, p.publish_at, p.deleted_at, p.quoted_post_id, p.explicit_tags,
+		EXISTS (SELECT 1 FROM pinned_posts pp WHERE pp.post_id = p.id),
+		` + mentionsColumn("post_id", "p") + `,
+		` + attachmentsColumn("p") + `,
+		u.id, u.username, u.is_private
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE ` + where
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
 	var post Post
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
 		&post.ID,
 		&post.UserID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Version,
 		pq.Array(&post.Tags),
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
+		&post.DeletedAt,
+		&post.QuotedPostID,
+		pq.Array(&post.ExplicitTags),
+		&post.Pinned,
+		(*mentionsJSON)(&post.Mentions),
+		(*mediaJSON)(&post.Attachments),
+		&post.User.ID,
+		&post.User.UserName,
+		&post.User.IsPrivate,
 	)
 
 	if err != nil {
@@ -144,42 +621,160 @@ func (s *PostStore) GetById(ctx context.Context, id int64) (*Post, error) {
 
 	return &post, nil
 }
-func (s *PostStore) Delete(ctx context.Context, postID int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
+
+// GetTrash returns the soft deleted posts of userID deleted after since, most recently deleted first.
+func (s *PostStore) GetTrash(ctx context.Context, userID int64, since time.Time, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$1") + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.user_id = $1 AND p.deleted_at >= $2
+	ORDER BY p.deleted_at DESC
+	LIMIT $3 OFFSET $4
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, userID, since, fq.Limit, fq.Offset)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+	return scanPostsWithMetaData(rows)
+}
 
-	if rows == 0 {
-		return ErrNotFound
-	}
+// Delete moves a post to the trash if it's still at version, failing with
+// ErrEditConflict otherwise, and drops the bookmarks of it. It stays
+// restorable until Purge removes it.
+func (s *PostStore) Delete(ctx context.Context, postID int64, version int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND version = $2 AND deleted_at IS NULL RETURNING user_id`
+
+		userID, err := s.setDeleted(ctx, tx, query, postID, version)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrEditConflict
+			}
+			return err
+		}
 
-	return nil
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM bookmarks WHERE post_id = $1`, postID); err != nil {
+			return err
+		}
+
+		return events.Publish(ctx, tx, events.PostDeleted, events.PostEvent{ID: postID, UserID: userID})
+	})
 }
-func (s *PostStore) Update(ctx context.Context, post *Post) error {
-	query := `UPDATE posts SET title = $1 , content = $2 , version = version +1
-	WHERE id = $3 AND version = $4
-	RETURNING version`
 
-	err := s.db.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version).Scan(&post.Version)
-	if err != nil {
+// Restore takes a post out of the trash, which listeners see as an update.
+func (s *PostStore) Restore(ctx context.Context, postID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING user_id`
+
+		userID, err := s.setDeleted(ctx, tx, query, postID)
+		if err != nil {
+			return err
+		}
+
+		return events.Publish(ctx, tx, events.PostUpdated, events.PostEvent{ID: postID, UserID: userID})
+	})
+}
+
+// setDeleted runs query, which sets or clears deleted_at, and returns the
+// author of the post.
+func (s *PostStore) setDeleted(ctx context.Context, tx *sql.Tx, query string, args ...any) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var userID int64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&userID); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			return 0, ErrNotFound
 		default:
-			return err
+			return 0, err
 		}
 	}
-	return nil
 
+	return userID, nil
+}
+
+// Purge hard deletes the posts trashed before the given time. Comments, pins
+// and revisions go with them through their ON DELETE CASCADE foreign keys.
+func (s *PostStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM posts WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Update saves the post if it's still at post.Version, failing with
+// ErrEditConflict otherwise, and snapshots the version it replaces into
+// post_revisions in the same transaction. A draft or scheduled post being
+// published gets its created_at reset, so it surfaces in feeds at publish time.
+// The mentions are replaced and the content rendered like in Create.
+func (s *PostStore) Update(ctx context.Context, post *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		revisions := &RevisionStore{s.db}
+		if err := revisions.create(ctx, tx, post.ID, post.Version); err != nil {
+			return err
+		}
+
+		post.ContentHTML = markdown.Render(post.Content)
+
+		query := `UPDATE posts SET title = $1 , content = $2 , visibility = $3, status = $4, publish_at = $5, tags = $6,
+		content_html = $9, explicit_tags = $10,
+		created_at = CASE WHEN status <> 'published' AND $4 = 'published' THEN NOW() ELSE created_at END,
+		updated_at = NOW(), version = version +1
+		FROM (SELECT status AS previous_status FROM posts WHERE id = $7) prev
+		WHERE id = $7 AND version = $8 AND deleted_at IS NULL
+		RETURNING version, created_at, updated_at, prev.previous_status`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		var previousStatus string
+		err := tx.QueryRowContext(
+			ctx, query, post.Title, post.Content, post.Visibility, post.Status, post.PublishAt, pq.Array(post.Tags),
+			post.ID, post.Version, post.ContentHTML, pq.Array(post.ExplicitTags),
+		).Scan(&post.Version, &post.CreatedAt, &post.UpdatedAt, &previousStatus)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		if err := replacePostTags(ctx, tx, post.ID, post.Tags); err != nil {
+			return err
+		}
+
+		post.Mentions, err = replaceMentions(ctx, tx, "post_id", post.ID, post.UserID, post.Mentions)
+		if err != nil {
+			return err
+		}
+
+		ev := events.PostEvent{ID: post.ID, UserID: post.UserID, Status: post.Status}
+		if err := events.Publish(ctx, tx, events.PostUpdated, ev); err != nil {
+			return err
+		}
+		if previousStatus != PostStatusPublished && post.Status == PostStatusPublished {
+			return events.Publish(ctx, tx, events.PostPublished, ev)
+		}
+		return nil
+	})
 }
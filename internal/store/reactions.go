@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// ReactionKinds are the reactions a post can get.
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// Reactions sums up the reactions to a post: how many of each kind it got,
+// and the kind the viewer reacted with, if any.
+type Reactions struct {
+	PostID int64          `json:"post_id"`
+	Counts map[string]int `json:"counts"`
+	Mine   *string        `json:"mine"`
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// Set reacts to postID with kind on behalf of userID, replacing the kind of an
+// earlier reaction. It reports whether the reaction is new, and fails with
+// ErrNotFound when the post doesn't exist.
+func (s *ReactionStore) Set(ctx context.Context, userID, postID int64, kind string) (bool, error) {
+	query := `
+	INSERT INTO reactions (user_id, post_id, kind) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, post_id) DO UPDATE SET kind = EXCLUDED.kind
+	RETURNING xmax = 0
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var created bool
+	if err := s.db.QueryRowContext(ctx, query, userID, postID, kind).Scan(&created); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return false, ErrNotFound
+		}
+		return false, err
+	}
+
+	return created, nil
+}
+
+func (s *ReactionStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM reactions WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Get counts the reactions to postID by kind, along with the one of viewerID.
+// Reactions of users viewerID has a block with aren't counted.
+func (s *ReactionStore) Get(ctx context.Context, postID, viewerID int64) (*Reactions, error) {
+	query := `
+	SELECT r.kind, COUNT(*), bool_or(r.user_id = $2)
+	FROM reactions r
+	WHERE r.post_id = $1 AND NOT ` + blockedClause("r.user_id", "$2") + `
+	GROUP BY r.kind
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := &Reactions{PostID: postID, Counts: map[string]int{}}
+	for rows.Next() {
+		var kind string
+		var count int
+		var mine bool
+		if err := rows.Scan(&kind, &count, &mine); err != nil {
+			return nil, err
+		}
+
+		reactions.Counts[kind] = count
+		if mine {
+			reactions.Mine = &kind
+		}
+	}
+
+	return reactions, rows.Err()
+}
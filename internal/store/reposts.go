@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrNotShareable = errors.New("only published public posts can be reposted or quoted")
+
+// QuotedPost is the original embedded in a quote post.
+type QuotedPost struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// quotedPostJSON scans the JSON object built by quotedPostColumn.
+type quotedPostJSON struct{ q **QuotedPost }
+
+func (j quotedPostJSON) Scan(src any) error {
+	if src == nil {
+		*j.q = nil
+		return nil
+	}
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("quoted post: expected a JSON object")
+	}
+	return json.Unmarshal(data, j.q)
+}
+
+// quotedPostObject builds the JSON object read into a QuotedPost from the
+// original aliased as q and its author as qu.
+const quotedPostObject = `json_build_object(
+	'id', q.id, 'user_id', q.user_id, 'username', qu.username, 'title', q.title,
+	'content', q.content, 'content_html', q.content_html, 'tags', q.tags, 'created_at', q.created_at
+)`
+
+// shareableClause is a SQL condition that is true when the post aliased as
+// postAlias, written by the user aliased as authorAlias, may be reposted or
+// quoted by the user bound to sharerParam.
+func shareableClause(postAlias, authorAlias, sharerParam string) string {
+	return fmt.Sprintf(`(%[1]s.deleted_at IS NULL AND %[1]s.status = 'published' AND %[2]s AND NOT %[3]s)`,
+		postAlias, discoverableClause(postAlias, authorAlias), blockedClause(postAlias+".user_id", sharerParam))
+}
+
+// quotedPostColumn is a select expression returning the post quoted by the
+// post aliased as postAlias as a JSON object. It is null when the post isn't
+// a quote, and also when the original was deleted or can't be read by the user
+// bound to viewerParam anymore, which quoted_post_id still tells apart.
+func quotedPostColumn(postAlias, viewerParam string) string {
+	return fmt.Sprintf(`(
+		SELECT `+quotedPostObject+`
+		FROM posts q JOIN users qu ON qu.id = q.user_id
+		WHERE q.id = %[1]s.quoted_post_id AND q.deleted_at IS NULL AND q.status = 'published' AND %[2]s
+	)`, postAlias, postVisibleClause("q", "qu", viewerParam))
+}
+
+type RepostStore struct {
+	db *sql.DB
+}
+
+// Create reposts postID on behalf of userID. Posts that aren't shareable,
+// see shareableClause, are reported as ErrNotShareable.
+func (s *RepostStore) Create(ctx context.Context, userID, postID int64) error {
+	query := `
+	INSERT INTO reposts (user_id, post_id)
+	SELECT $1, p.id FROM posts p JOIN users u ON u.id = p.user_id
+	WHERE p.id = $2 AND ` + shareableClause("p", "u", "$1")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotShareable
+	}
+
+	return nil
+}
+
+func (s *RepostStore) Delete(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostRevision is an immutable snapshot of a post as it was at Version.
+type PostRevision struct {
+	PostID    int64    `json:"post_id"`
+	Version   int      `json:"version"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type RevisionStore struct {
+	db *sql.DB
+}
+
+// create snapshots the post as stored at version before it gets overwritten.
+// The primary key on (post_id, version) makes a concurrent update of the same
+// version fail; that and any other version mismatch is reported as ErrEditConflict.
+func (s *RevisionStore) create(ctx context.Context, tx *sql.Tx, postID int64, version int) error {
+	query := `
+	INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+	SELECT id, version, title, content, tags, updated_at FROM posts
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, postID, version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	query = `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`
+	if err := tx.QueryRowContext(ctx, query, postID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrEditConflict
+	}
+
+	return ErrNotFound
+}
+
+func (s *RevisionStore) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `
+	SELECT post_id, version, title, content, tags, created_at
+	FROM post_revisions WHERE post_id = $1
+	ORDER BY version DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+
+	for rows.Next() {
+		var rev PostRevision
+
+		err := rows.Scan(&rev.PostID, &rev.Version, &rev.Title, &rev.Content, pq.Array(&rev.Tags), &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *RevisionStore) Get(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `
+	SELECT post_id, version, title, content, tags, created_at
+	FROM post_revisions WHERE post_id = $1 AND version = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var rev PostRevision
+	err := s.db.QueryRowContext(ctx, query, postID, version).Scan(
+		&rev.PostID, &rev.Version, &rev.Title, &rev.Content, pq.Array(&rev.Tags), &rev.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rev, nil
+}
@@ -10,6 +10,8 @@ import (
 var (
 	ErrNotFound          = errors.New("resource not found")
 	ErrConflict          = errors.New("resource already exists")
+	ErrBlocked           = errors.New("action not allowed between these users")
+	ErrEditConflict      = errors.New("edit conflict, the resource was modified concurrently")
 	QueryTimeOutDuration = time.Second * 5
 )
 
@@ -17,9 +19,19 @@ type Storage struct {
 	Posts interface {
 		GetById(context.Context, int64) (*Post, error)
 		Create(context.Context, *Post) error
-		Delete(context.Context, int64) error
+		Delete(ctx context.Context, postID int64, version int) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetUserPosts(ctx context.Context, authorID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetPublicTimeline(ctx context.Context, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetByTag(ctx context.Context, tag string, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetDrafts(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error)
+		FilterFeedViewers(ctx context.Context, postID int64, viewerIDs []int64) ([]int64, error)
+		PublishDue(ctx context.Context, limit int) ([]int64, error)
+		GetTrashedById(context.Context, int64) (*Post, error)
+		GetTrash(ctx context.Context, userID int64, since time.Time, fq PaginatedFeedQuery) ([]PostWithMetaData, error)
+		Restore(context.Context, int64) error
+		Purge(ctx context.Context, before time.Time) (int64, error)
 	}
 	Users interface {
 		GetById(context.Context, int64) (*User, error)
@@ -28,15 +40,146 @@ type Storage struct {
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
+		SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(context.Context, int64) ([]*Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) ([]*Comment, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		Update(context.Context, *Comment) error
+		GetTrashedByID(context.Context, int64) (*Comment, error)
+		Delete(context.Context, int64) error
+		Restore(context.Context, int64) error
+		Purge(ctx context.Context, before time.Time) (int64, error)
 	}
 
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID int64) error
 		Unfollow(ctx context.Context, followerID, userID int64) error
+		IsFollowing(ctx context.Context, followerID, userID int64) (bool, error)
+		GetFollowerIDs(context.Context, int64) ([]int64, error)
+	}
+
+	FollowRequests interface {
+		Create(ctx context.Context, followerID, userID int64) error
+		GetIncoming(ctx context.Context, userID int64, status string) ([]FollowRequest, error)
+		Accept(ctx context.Context, userID, followerID int64) error
+		Reject(ctx context.Context, userID, followerID int64) error
+		Delete(ctx context.Context, userID, followerID int64) error
+	}
+
+	Revisions interface {
+		GetByPostID(context.Context, int64) ([]PostRevision, error)
+		Get(ctx context.Context, postID int64, version int) (*PostRevision, error)
+	}
+
+	Pins interface {
+		Pin(ctx context.Context, userID, postID int64, limit int) error
+		Unpin(ctx context.Context, userID, postID int64) error
+	}
+
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int64) error
+		Unblock(ctx context.Context, blockerID, blockedID int64) error
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+		GetBlocked(ctx context.Context, blockerID int64) ([]Block, error)
+	}
+
+	Mutes interface {
+		Mute(ctx context.Context, muterID, mutedID int64) error
+		Unmute(ctx context.Context, muterID, mutedID int64) error
+		GetMuted(ctx context.Context, muterID int64) ([]Mute, error)
+	}
+
+	FeedFilters interface {
+		Create(context.Context, *FeedFilter) error
+		GetByID(ctx context.Context, id, userID int64) (*FeedFilter, error)
+		GetByUserID(context.Context, int64) ([]FeedFilter, error)
+		Update(context.Context, *FeedFilter) error
+		Delete(ctx context.Context, id, userID int64) error
+	}
+
+	Tags interface {
+		Search(ctx context.Context, prefix string, limit int) ([]Tag, error)
+	}
+
+	Trending interface {
+		Refresh(ctx context.Context, period string, limit int) error
+		GetTags(ctx context.Context, period string, limit int) ([]TrendingTag, error)
+		GetPosts(ctx context.Context, period string, viewerID int64, limit int) ([]PostWithMetaData, error)
+	}
+
+	Notifications interface {
+		Create(context.Context, *Notification) error
+		GetByUserID(ctx context.Context, userID int64, unreadOnly bool, limit, offset int) ([]NotificationGroup, error)
+		CountUnread(context.Context, int64) (int, error)
+		IsVisible(ctx context.Context, id int64) (bool, error)
+		MarkRead(ctx context.Context, userID, id int64) error
+		MarkAllRead(context.Context, int64) error
+	}
+
+	Polls interface {
+		Get(ctx context.Context, postID, viewerID int64) (*Poll, error)
+		Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error
+	}
+
+	Media interface {
+		Create(ctx context.Context, m *Media) error
+		GetByID(ctx context.Context, id, userID int64) (*Media, error)
+		UpdateAltText(ctx context.Context, id, userID int64, altText string) error
+		Delete(ctx context.Context, id, userID int64) error
+		PurgeOrphans(ctx context.Context, before time.Time, limit int) ([]Media, error)
+	}
+
+	Reposts interface {
+		Create(ctx context.Context, userID, postID int64) error
+		Delete(ctx context.Context, userID, postID int64) error
+	}
+
+	Reactions interface {
+		Set(ctx context.Context, userID, postID int64, kind string) (bool, error)
+		Delete(ctx context.Context, userID, postID int64) error
+		Get(ctx context.Context, postID, viewerID int64) (*Reactions, error)
+	}
+
+	Bookmarks interface {
+		Add(ctx context.Context, userID, postID int64, collectionID *int64) error
+		Remove(ctx context.Context, userID, postID int64) error
+		GetPosts(ctx context.Context, userID int64, collectionID *int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error)
+	}
+
+	BookmarkCollections interface {
+		Create(context.Context, *BookmarkCollection) error
+		GetByID(ctx context.Context, id, userID int64) (*BookmarkCollection, error)
+		GetByUserID(context.Context, int64) ([]BookmarkCollection, error)
+		Rename(context.Context, *BookmarkCollection) error
+		Delete(ctx context.Context, id, userID int64) error
+	}
+
+	Conversations interface {
+		Create(ctx context.Context, c *Conversation, participantIDs []int64) error
+		GetByID(ctx context.Context, id, userID int64) (*Conversation, error)
+		GetByUserID(ctx context.Context, userID int64, limit, offset int) ([]Conversation, error)
+		MarkRead(ctx context.Context, id, userID, messageID int64) error
+	}
+
+	Messages interface {
+		Create(context.Context, *Message) error
+		GetByConversationID(ctx context.Context, conversationID, viewerID, before int64, limit int) ([]Message, error)
+	}
+
+	Webhooks interface {
+		Create(context.Context, *Webhook) error
+		GetByID(ctx context.Context, id, userID int64) (*Webhook, error)
+		GetByUserID(context.Context, int64) ([]Webhook, error)
+		Update(context.Context, *Webhook) error
+		Delete(ctx context.Context, id, userID int64) error
+		Enqueue(ctx context.Context, userID int64, eventType string, payload []byte) (int64, error)
+		ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error)
+		RecordSuccess(ctx context.Context, d *DueDelivery, responseStatus int) error
+		RecordFailure(ctx context.Context, d *DueDelivery, responseStatus int, cause string, retryAt *time.Time, maxFailures int) (bool, error)
+		GetDeliveries(ctx context.Context, webhookID int64, limit, offset int) ([]WebhookDelivery, error)
+		PurgeDeliveries(ctx context.Context, before time.Time) (int64, error)
 	}
 
 	Roles interface {
@@ -46,11 +189,29 @@ type Storage struct {
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:               &PostStore{db},
+		Users:               &UserStore{db},
+		Comments:            &CommentStore{db},
+		Followers:           &FollowerStore{db},
+		FollowRequests:      &FollowRequestStore{db},
+		Revisions:           &RevisionStore{db},
+		Pins:                &PinStore{db},
+		Blocks:              &BlockStore{db},
+		Mutes:               &MuteStore{db},
+		FeedFilters:         &FeedFilterStore{db},
+		Tags:                &TagStore{db},
+		Trending:            &TrendingStore{db},
+		Notifications:       &NotificationStore{db},
+		Polls:               &PollStore{db},
+		Media:               &MediaStore{db},
+		Reposts:             &RepostStore{db},
+		Reactions:           &ReactionStore{db},
+		Bookmarks:           &BookmarkStore{db},
+		BookmarkCollections: &BookmarkCollectionStore{db},
+		Conversations:       &ConversationStore{db},
+		Messages:            &MessageStore{db},
+		Webhooks:            &WebhookStore{db},
+		Roles:               &RoleStore{db},
 	}
 }
 func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// replacePostTags stores tags as the tags of the post postID in post_tags,
+// replacing the previous ones, so they match posts.tags.
+func replacePostTags(ctx context.Context, tx *sql.Tx, postID int64, tags []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = $1`, postID); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO post_tags (post_id, tag)
+	SELECT $1, t.tag FROM unnest($2::varchar(100)[]) AS t(tag)
+	ON CONFLICT DO NOTHING
+	`
+	_, err := tx.ExecContext(ctx, query, postID, pq.Array(tags))
+	return err
+}
+
+// Search returns the tags starting with prefix, most used first. Matching tags
+// are found through the prefix index of post_tags, and only tags of posts
+// anyone can discover are counted, so tags of followers-only posts, unlisted
+// posts or private accounts don't leak through autocomplete.
+func (s *TagStore) Search(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	query := `
+	SELECT pt.tag, COUNT(*) AS post_count
+	FROM post_tags pt
+	JOIN posts p ON p.id = pt.post_id
+	JOIN users u ON p.user_id = u.id
+	WHERE
+		pt.tag LIKE $1 ESCAPE '\' AND
+		p.deleted_at IS NULL AND
+		p.status = 'published' AND
+		` + discoverableClause("p", "u") + `
+	GROUP BY pt.tag
+	ORDER BY post_count DESC, pt.tag
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// tags may contain underscores, which LIKE reads as a wildcard
+	pattern := strings.ReplaceAll(prefix, "_", `\_`) + "%"
+
+	rows, err := s.db.QueryContext(ctx, query, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
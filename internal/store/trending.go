@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TrendingPeriods are the sliding windows trending snapshots are computed over.
+var TrendingPeriods = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  time.Hour * 24,
+	"week": time.Hour * 24 * 7,
+}
+
+// Weights of each kind of activity in a trending score. A comment says more
+// about a post catching on than the post being written, a reaction less.
+const (
+	trendingPostWeight     = 1.0
+	trendingCommentWeight  = 2.0
+	trendingReactionWeight = 0.5
+)
+
+type TrendingTag struct {
+	Tag        string  `json:"tag"`
+	Score      float64 `json:"score"`
+	PostCount  int     `json:"post_count"`
+	ComputedAt string  `json:"computed_at"`
+}
+
+type TrendingStore struct {
+	db *sql.DB
+}
+
+// trendingActivity lists the post_id, time and weight of every post written,
+// comment left and reaction given within the period of $1 seconds. Each one is worth its
+// weight halved every quarter of the period, so recent activity ranks higher
+// than what happened at the start of the window.
+var trendingActivity = `
+	WITH activity AS (
+		SELECT p.id AS post_id, p.created_at AS at, $2::float8 AS weight
+		FROM posts p
+		WHERE p.created_at > NOW() - make_interval(secs => $1)
+		UNION ALL
+		SELECT c.post_id, c.created_at, $3::float8
+		FROM comments c
+		WHERE c.created_at > NOW() - make_interval(secs => $1) AND c.deleted_at IS NULL
+		UNION ALL
+		SELECT r.post_id, r.created_at, $6::float8
+		FROM reactions r
+		WHERE r.created_at > NOW() - make_interval(secs => $1)
+	),
+	decayed AS (
+		SELECT a.post_id, a.weight * exp(-ln(2) * extract(epoch FROM NOW() - a.at) / ($1 / 4)) AS score
+		FROM activity a
+		JOIN posts p ON p.id = a.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NULL AND p.status = 'published' AND ` + discoverableClause("p", "u") + `
+	)
+`
+
+// Refresh recomputes the trending tags and posts snapshot of period, keeping
+// the limit best of each. Only one replica refreshes a period at a time, the
+// others skip it until the next tick.
+func (s *TrendingStore) Refresh(ctx context.Context, period string, limit int) error {
+	length, ok := TrendingPeriods[period]
+	if !ok {
+		return ErrNotFound
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration*6)
+		defer cancel()
+
+		var locked bool
+		query := `SELECT pg_try_advisory_xact_lock(hashtext('trending:' || $1))`
+		if err := tx.QueryRowContext(ctx, query, period).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM trending_tags WHERE period = $1`, period); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM trending_posts WHERE period = $1`, period); err != nil {
+			return err
+		}
+
+		args := []any{length.Seconds(), trendingPostWeight, trendingCommentWeight, period, limit, trendingReactionWeight}
+
+		query = trendingActivity + `
+		INSERT INTO trending_tags (period, tag, score, post_count)
+		SELECT $4, t.tag, SUM(d.score) AS score, COUNT(DISTINCT d.post_id)
+		FROM decayed d
+		JOIN posts p ON p.id = d.post_id
+		CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+		GROUP BY t.tag
+		ORDER BY score DESC
+		LIMIT $5
+		`
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+
+		query = trendingActivity + `
+		INSERT INTO trending_posts (period, post_id, score)
+		SELECT $4, d.post_id, SUM(d.score) AS score
+		FROM decayed d
+		GROUP BY d.post_id
+		ORDER BY score DESC
+		LIMIT $5
+		`
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// GetTags returns the last snapshot of trending tags of period, best first.
+func (s *TrendingStore) GetTags(ctx context.Context, period string, limit int) ([]TrendingTag, error) {
+	query := `
+	SELECT tag, score, post_count, computed_at
+	FROM trending_tags
+	WHERE period = $1
+	ORDER BY score DESC, tag
+	LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, period, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Score, &t.PostCount, &t.ComputedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetPosts returns the last snapshot of trending posts of period, best first.
+// Posts deleted or hidden from viewerID since the snapshot was taken, and
+// posts by authors viewerID muted, are left out.
+func (s *TrendingStore) GetPosts(ctx context.Context, period string, viewerID int64, limit int) ([]PostWithMetaData, error) {
+	query := `
+	SELECT ` + postWithMetaDataColumns("$2") + `
+	FROM trending_posts tp
+	JOIN posts p ON p.id = tp.post_id
+	JOIN users u ON p.user_id = u.id
+	WHERE
+		tp.period = $1 AND
+		p.deleted_at IS NULL AND
+		` + postVisibleClause("p", "u", "$2") + ` AND
+		NOT ` + mutedClause("p.user_id", "$2") + `
+	ORDER BY tp.score DESC, p.id DESC
+	LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, period, viewerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostsWithMetaData(rows)
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"errors"
+	"social/internal/events"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -23,6 +24,7 @@ type User struct {
 	Password  password `json:"-"`
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
+	IsPrivate bool     `json:"is_private"`
 	RoleID    int64    `json:"role_id"`
 	Role      Role     `json:"role"`
 }
@@ -73,7 +75,7 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 }
 
 func (s *UserStore) GetById(ctx context.Context, userID int64) (*User, error) {
-	query := `SELECT users.id,username,email,password,created_at, roles.* FROM users JOIN roles ON (users.role_id = roles.id) WHERE users.id = $1  AND is_active = true`
+	query := `SELECT users.id,username,email,password,created_at,is_private, roles.* FROM users JOIN roles ON (users.role_id = roles.id) WHERE users.id = $1  AND is_active = true`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
@@ -81,7 +83,7 @@ func (s *UserStore) GetById(ctx context.Context, userID int64) (*User, error) {
 	user := &User{}
 	err := s.db.QueryRowContext(ctx, query, userID).Scan(
 		&user.ID,
-		&user.UserName, &user.Email, &user.Password.hash, &user.CreatedAt, &user.Role.ID, &user.Role.Name, &user.Role.Level, &user.Role.Description,
+		&user.UserName, &user.Email, &user.Password.hash, &user.CreatedAt, &user.IsPrivate, &user.Role.ID, &user.Role.Name, &user.Role.Level, &user.Role.Description,
 	)
 
 	if err != nil {
@@ -133,7 +135,7 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 		if err := s.deleteUserInvitations(ctx, tx, user.ID); err != nil {
 			return err
 		}
-		return nil
+		return events.Publish(ctx, tx, events.UserUpdated, events.UserEvent{ID: user.ID})
 	})
 
 }
@@ -241,6 +243,31 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 	return nil
 }
 
+func (s *UserStore) SetPrivacy(ctx context.Context, userID int64, isPrivate bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE users SET is_private = $1 WHERE id = $2`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, isPrivate, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return events.Publish(ctx, tx, events.UserUpdated, events.UserEvent{ID: userID})
+	})
+}
+
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `SELECT id,username,email,password,created_at FROM users WHERE email = $1 AND is_active = true`
 
@@ -0,0 +1,30 @@
+package store
+
+import "fmt"
+
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityUnlisted  = "unlisted"
+)
+
+// postVisibleClause is a SQL condition that is true when the user bound to
+// viewerParam may read the post aliased as postAlias, written by the user
+// aliased as authorAlias. Authors always see their own posts; everyone else
+// needs no block in either direction, and followers-only posts or posts by
+// private accounts also need an accepted follow.
+func postVisibleClause(postAlias, authorAlias, viewerParam string) string {
+	return fmt.Sprintf(`(%[1]s.user_id = %[3]s OR (
+		NOT %[4]s AND (
+			(%[1]s.visibility IN ('public', 'unlisted') AND NOT %[2]s.is_private)
+			OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = %[1]s.user_id AND vf.follower_id = %[3]s)
+		)
+	))`, postAlias, authorAlias, viewerParam, blockedClause(postAlias+".user_id", viewerParam))
+}
+
+// discoverableClause is a SQL condition that is true when the post aliased as
+// postAlias, written by the user aliased as authorAlias, may show up to people
+// who don't follow its author: in explore, trending and tag autocomplete.
+func discoverableClause(postAlias, authorAlias string) string {
+	return fmt.Sprintf(`(%[1]s.visibility = 'public' AND NOT %[2]s.is_private)`, postAlias, authorAlias)
+}
@@ -0,0 +1,389 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	WebhookPostCreated    = "post.created"
+	WebhookPostUpdated    = "post.updated"
+	WebhookPostDeleted    = "post.deleted"
+	WebhookCommentCreated = "comment.created"
+	WebhookFollow         = "follow"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	URL    string `json:"url"`
+	// Secret is only returned when the webhook is created
+	Secret       string     `json:"secret,omitempty"`
+	Events       []string   `json:"events"`
+	Active       bool       `json:"active"`
+	FailureCount int        `json:"failure_count"`
+	DisabledAt   *time.Time `json:"disabled_at"`
+	CreatedAt    string     `json:"created_at"`
+	UpdatedAt    string     `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	Error          *string         `json:"error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// DueDelivery is a delivery claimed by the dispatcher, along with what it
+// needs to send it.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+const webhookColumns = `id, user_id, url, events, active, failure_count, disabled_at, created_at, updated_at`
+
+func scanWebhook(row interface{ Scan(...any) error }, w *Webhook) error {
+	return row.Scan(
+		&w.ID, &w.UserID, &w.URL, pq.Array(&w.Events), &w.Active, &w.FailureCount, &w.DisabledAt,
+		&w.CreatedAt, &w.UpdatedAt,
+	)
+}
+
+func (s *WebhookStore) Create(ctx context.Context, w *Webhook) error {
+	query := `
+	INSERT INTO webhooks (user_id, url, secret, events)
+	VALUES ($1, $2, $3, $4) RETURNING active, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx, query, w.UserID, w.URL, w.Secret, pq.Array(w.Events),
+	).Scan(&w.Active, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func (s *WebhookStore) GetByID(ctx context.Context, id, userID int64) (*Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var w Webhook
+	if err := scanWebhook(s.db.QueryRowContext(ctx, query, id, userID), &w); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &w, nil
+}
+
+func (s *WebhookStore) GetByUserID(ctx context.Context, userID int64) ([]Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var w Webhook
+		if err := scanWebhook(rows, &w); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Update saves the URL, events and active flag of a webhook. Turning a
+// disabled webhook back on resets its failure count.
+// purgePendingDeliveries drops the deliveries of a disabled webhook still
+// waiting to be sent, so re-enabling it doesn't flush stale events.
+const purgePendingDeliveries = `DELETE FROM webhook_deliveries WHERE webhook_id = $1 AND status = 'pending'`
+
+// Update saves the webhook. Disabling it drops its pending deliveries.
+func (s *WebhookStore) Update(ctx context.Context, w *Webhook) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		UPDATE webhooks SET url = $1, events = $2, active = $3, updated_at = NOW(),
+			failure_count = CASE WHEN $3 AND NOT active THEN 0 ELSE failure_count END,
+			disabled_at = CASE WHEN $3 THEN NULL ELSE disabled_at END
+		WHERE id = $4 AND user_id = $5
+		RETURNING failure_count, disabled_at, updated_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx, query, w.URL, pq.Array(w.Events), w.Active, w.ID, w.UserID,
+		).Scan(&w.FailureCount, &w.DisabledAt, &w.UpdatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if !w.Active {
+			if _, err := tx.ExecContext(ctx, purgePendingDeliveries, w.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Enqueue queues a delivery of payload to every active webhook of userID
+// subscribed to eventType and returns how many were queued.
+func (s *WebhookStore) Enqueue(ctx context.Context, userID int64, eventType string, payload []byte) (int64, error) {
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+	SELECT id, $2, $3 FROM webhooks
+	WHERE user_id = $1 AND active AND $2 = ANY(events)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, eventType, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ClaimDue claims up to limit pending deliveries of active webhooks that are
+// due, counting the attempt and pushing next_attempt_at by lease so other
+// replicas leave them alone while they are sent. A delivery whose sender died
+// is picked up again once the lease runs out.
+func (s *WebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	query := `
+	UPDATE webhook_deliveries d
+	SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT dd.id FROM webhook_deliveries dd
+		JOIN webhooks ww ON ww.id = dd.webhook_id
+		WHERE dd.status = 'pending' AND dd.next_attempt_at <= NOW() AND ww.active
+		ORDER BY dd.next_attempt_at
+		LIMIT $1
+		FOR UPDATE OF dd SKIP LOCKED
+	)
+	RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at,
+		w.url, w.secret
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.CreatedAt,
+			&d.URL, &d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+
+	return due, rows.Err()
+}
+
+// RecordSuccess marks a delivery as delivered and resets the failure count of
+// its webhook.
+func (s *WebhookStore) RecordSuccess(ctx context.Context, d *DueDelivery, responseStatus int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		query := `
+		UPDATE webhook_deliveries SET status = 'succeeded', response_status = $1, error = NULL, delivered_at = NOW()
+		WHERE id = $2
+		`
+		if _, err := tx.ExecContext(ctx, query, responseStatus, d.ID); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `UPDATE webhooks SET failure_count = 0 WHERE id = $1`, d.WebhookID)
+		return err
+	})
+}
+
+// RecordFailure logs a failed attempt. The delivery is retried at retryAt, or
+// marked as failed when retryAt is nil. The webhook is disabled once it failed
+// maxFailures times in a row, in which case disabled is true and its other
+// pending deliveries are dropped.
+func (s *WebhookStore) RecordFailure(
+	ctx context.Context, d *DueDelivery, responseStatus int, cause string, retryAt *time.Time, maxFailures int,
+) (disabled bool, err error) {
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		var status *int
+		if responseStatus != 0 {
+			status = &responseStatus
+		}
+
+		query := `
+		UPDATE webhook_deliveries SET
+			status = CASE WHEN $1::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($1, next_attempt_at),
+			response_status = $2, error = $3
+		WHERE id = $4
+		`
+		if _, err := tx.ExecContext(ctx, query, retryAt, status, cause, d.ID); err != nil {
+			return err
+		}
+
+		query = `
+		UPDATE webhooks SET failure_count = failure_count + 1,
+			active = failure_count + 1 < $1,
+			disabled_at = CASE WHEN failure_count + 1 >= $1 THEN NOW() ELSE disabled_at END
+		WHERE id = $2 AND active
+		RETURNING NOT active
+		`
+		err := tx.QueryRowContext(ctx, query, maxFailures, d.WebhookID).Scan(&disabled)
+		if errors.Is(err, sql.ErrNoRows) {
+			// disabled meanwhile
+			return nil
+		}
+		if err != nil || !disabled {
+			return err
+		}
+
+		// the attempt that disabled the webhook stays in the log
+		query = `UPDATE webhook_deliveries SET status = 'failed' WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, d.ID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, purgePendingDeliveries, d.WebhookID)
+		return err
+	})
+
+	return disabled, err
+}
+
+// GetDeliveries returns the delivery log of a webhook, latest first.
+func (s *WebhookStore) GetDeliveries(ctx context.Context, webhookID int64, limit, offset int) ([]WebhookDelivery, error) {
+	query := `
+	SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at, response_status, error,
+		created_at, delivered_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.ResponseStatus, &d.Error, &d.CreatedAt, &d.DeliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// PurgeDeliveries removes the finished deliveries created before the given time.
+func (s *WebhookStore) PurgeDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
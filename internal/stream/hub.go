@@ -0,0 +1,173 @@
+// Package stream fans real-time events out to the clients connected to this
+// API, such as the server-sent events endpoint.
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrOverflow closes a subscription whose client didn't keep up with its
+// events. The client is expected to reconnect and resume from the last event
+// it received.
+var ErrOverflow = errors.New("stream: subscriber fell behind")
+
+// Event is a message published on a topic. IDs increase with every event
+// published on the hub, so a client can resume after the last one it saw.
+type Event struct {
+	ID    uint64          `json:"id"`
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Hub delivers published events to the subscribers of their topic. The
+// in-memory implementation only reaches subscribers of the same process;
+// one backed by Postgres LISTEN/NOTIFY can fan events out to every replica
+// behind the same interface.
+type Hub interface {
+	Publish(topic, eventType string, data any) error
+	// Subscribe starts delivering the events of topics. Events published
+	// after lastID that are still in the hub's history are replayed first;
+	// resumed is false when some of them were already dropped from it.
+	Subscribe(topics []string, lastID uint64) (sub *Subscription, resumed bool)
+}
+
+// Subscription receives the events of its topics on C until it is closed,
+// either by the subscriber or by the hub when its buffer overflows.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	topics []string
+	hub    *MemoryHub
+	once   sync.Once
+	closed bool
+	err    error
+}
+
+// Err reports why the hub closed the subscription, if it did.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close stops the subscription and releases it from the hub.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s, nil)
+}
+
+// MemoryHub is a Hub for a single process. It keeps the last events
+// published so reconnecting clients can catch up.
+type MemoryHub struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []Event
+	size    int
+	buffer  int
+	subs    map[string]map[*Subscription]struct{}
+}
+
+// NewMemoryHub returns a hub remembering the last history events, whose
+// subscribers can fall up to buffer events behind before being closed.
+func NewMemoryHub(history, buffer int) *MemoryHub {
+	return &MemoryHub{
+		size:   history,
+		buffer: buffer,
+		subs:   map[string]map[*Subscription]struct{}{},
+	}
+}
+
+func (h *MemoryHub) Publish(topic, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	e := Event{ID: h.lastID, Topic: topic, Type: eventType, Data: raw}
+
+	h.history = append(h.history, e)
+	if len(h.history) > h.size {
+		h.history = h.history[len(h.history)-h.size:]
+	}
+
+	for sub := range h.subs[topic] {
+		h.deliver(sub, e)
+	}
+
+	return nil
+}
+
+func (h *MemoryHub) Subscribe(topics []string, lastID uint64) (*Subscription, bool) {
+	c := make(chan Event, h.buffer)
+	sub := &Subscription{C: c, c: c, topics: topics, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	resumed := true
+	if lastID > 0 {
+		// an event after lastID was dropped if the oldest one kept is newer
+		// and lastID can't be known if the hub restarted since
+		if lastID > h.lastID || (len(h.history) > 0 && h.history[0].ID > lastID+1) {
+			resumed = false
+		}
+
+		wanted := make(map[string]bool, len(topics))
+		for _, t := range topics {
+			wanted[t] = true
+		}
+		for _, e := range h.history {
+			if e.ID > lastID && wanted[e.Topic] {
+				h.deliver(sub, e)
+			}
+		}
+	}
+
+	// the replay alone can overflow the buffer
+	if sub.closed {
+		return sub, resumed
+	}
+
+	for _, t := range topics {
+		if h.subs[t] == nil {
+			h.subs[t] = map[*Subscription]struct{}{}
+		}
+		h.subs[t][sub] = struct{}{}
+	}
+
+	return sub, resumed
+}
+
+// deliver hands e to sub without blocking the publisher, closing sub when
+// its buffer is full. Callers hold h.mu.
+func (h *MemoryHub) deliver(sub *Subscription, e Event) {
+	select {
+	case sub.c <- e:
+	default:
+		h.remove(sub, ErrOverflow)
+	}
+}
+
+// remove unregisters sub and closes its channel once. Callers hold h.mu.
+func (h *MemoryHub) remove(sub *Subscription, err error) {
+	sub.once.Do(func() {
+		sub.closed = true
+		sub.err = err
+		for _, t := range sub.topics {
+			delete(h.subs[t], sub)
+			if len(h.subs[t]) == 0 {
+				delete(h.subs, t)
+			}
+		}
+		close(sub.c)
+	})
+}
@@ -0,0 +1,149 @@
+// Package tags normalizes post tags and extracts hashtags from post content.
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLength is the longest tag accepted, in characters.
+	MaxLength = 50
+	// MaxPerPost is the most tags a post can carry, hashtags included.
+	MaxPerPost = 10
+)
+
+var (
+	ErrInvalid  = errors.New("tags may only contain letters, digits and underscores, and at least one letter")
+	ErrTooLong  = fmt.Errorf("tags can be at most %d characters long", MaxLength)
+	ErrTooMany  = fmt.Errorf("a post can have at most %d tags", MaxPerPost)
+	hashtagExpr = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_]+)`)
+)
+
+// Normalize case-folds tag and drops a leading '#', then checks its length
+// and charset.
+func Normalize(tag string) (string, error) {
+	return normalize(tag, true)
+}
+
+// NormalizePrefix normalizes the start of a tag, which unlike a whole tag may
+// be made of digits only.
+func NormalizePrefix(prefix string) (string, error) {
+	return normalize(prefix, false)
+}
+
+func normalize(tag string, needLetter bool) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+
+	if utf8.RuneCountInString(tag) > MaxLength {
+		return "", ErrTooLong
+	}
+
+	hasLetter := !needLetter
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r), r == '_':
+		default:
+			return "", ErrInvalid
+		}
+	}
+	if tag == "" || !hasLetter {
+		return "", ErrInvalid
+	}
+
+	return tag, nil
+}
+
+// NormalizeAll normalizes every tag, dropping duplicates while keeping the
+// order of first appearance.
+func NormalizeAll(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag, err := Normalize(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+
+	if len(out) > MaxPerPost {
+		return nil, ErrTooMany
+	}
+
+	return out, nil
+}
+
+// Extract returns the normalized hashtags of content in order of first
+// appearance. Hashtags that aren't valid tags are ignored.
+func Extract(content string) []string {
+	var out []string
+	seen := map[string]bool{}
+
+	for _, m := range hashtagExpr.FindAllStringSubmatch(content, -1) {
+		tag, err := Normalize(m[1])
+		if err != nil || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+
+	return out
+}
+
+// Merge returns the explicit tags followed by the hashtags of content that
+// aren't among them. Hashtags beyond MaxPerPost are dropped rather than
+// failing the write, explicit tags are expected to be within it already.
+func Merge(explicit []string, content string) []string {
+	out := append([]string{}, explicit...)
+	seen := make(map[string]bool, len(explicit))
+	for _, tag := range explicit {
+		seen[tag] = true
+	}
+
+	for _, tag := range Extract(content) {
+		if len(out) >= MaxPerPost {
+			break
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
+
+// Explicit tells which of the stored tags of a post, as kept by revisions,
+// were set explicitly: the ones that aren't hashtags of content, and those in
+// known, the tags currently known to be explicit.
+func Explicit(stored []string, content string, known []string) []string {
+	extracted := map[string]bool{}
+	for _, tag := range Extract(content) {
+		extracted[tag] = true
+	}
+	for _, tag := range known {
+		extracted[tag] = false
+	}
+
+	out := []string{}
+	for _, tag := range stored {
+		if !extracted[tag] {
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,135 @@
+package tags
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr error
+	}{
+		{"golang", "golang", nil},
+		{"#GoLang", "golang", nil},
+		{"  #go_1 ", "go_1", nil},
+		{"Ünïcode", "ünïcode", nil},
+		{"日本", "日本", nil},
+		{"", "", ErrInvalid},
+		{"#", "", ErrInvalid},
+		{"2024", "", ErrInvalid},
+		{"go-lang", "", ErrInvalid},
+		{"go lang", "", ErrInvalid},
+		{"##go", "", ErrInvalid},
+		{strings.Repeat("a", MaxLength), strings.Repeat("a", MaxLength), nil},
+		{strings.Repeat("é", MaxLength), strings.Repeat("é", MaxLength), nil},
+		{strings.Repeat("a", MaxLength+1), "", ErrTooLong},
+	}
+
+	for _, tt := range tests {
+		got, err := Normalize(tt.tag)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.tag, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		want    string
+		wantErr error
+	}{
+		{"#Go", "go", nil},
+		{"20", "20", nil},
+		{"", "", ErrInvalid},
+		{"a%", "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePrefix(tt.prefix)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("NormalizePrefix(%q) = %q, %v, want %q, %v", tt.prefix, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	got, err := NormalizeAll([]string{"Go", "#go", "web", "GO", "api"})
+	if err != nil {
+		t.Fatalf("NormalizeAll() error = %v", err)
+	}
+	if want := []string{"go", "web", "api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeAll() = %v, want %v", got, want)
+	}
+
+	if _, err := NormalizeAll([]string{"go", "bad tag"}); err != ErrInvalid {
+		t.Errorf("NormalizeAll() with an invalid tag error = %v, want %v", err, ErrInvalid)
+	}
+
+	var many []string
+	for i := 0; i <= MaxPerPost; i++ {
+		many = append(many, "tag"+strconv.Itoa(i))
+	}
+	if _, err := NormalizeAll(many); err != ErrTooMany {
+		t.Errorf("NormalizeAll() with %d tags error = %v, want %v", len(many), err, ErrTooMany)
+	}
+
+	// duplicates don't count against the limit
+	dupes := append(many[:MaxPerPost:MaxPerPost], "TAG0", "#tag1")
+	if _, err := NormalizeAll(dupes); err != nil {
+		t.Errorf("NormalizeAll() with %d distinct tags error = %v", MaxPerPost, err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"start and middle", "#Go is fun #web", []string{"go", "web"}},
+		{"duplicates", "#go #Go #GO", []string{"go"}},
+		{"punctuation after", "love #golang, really", []string{"golang"}},
+		{"digits only", "issue #123", nil},
+		{"inside a word", "c#sharp", nil},
+		{"url fragment", "https://example.com/#anchor", nil},
+		{"html entity", "&#39;", nil},
+		{"double hash", "##go", nil},
+		{"too long", "#" + strings.Repeat("a", MaxLength+1), nil},
+		{"unicode", "voilà #café", []string{"café"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Extract(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got := Merge([]string{"go", "api"}, "#web and #go")
+	if want := []string{"go", "api", "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %v, want %v", got, want)
+	}
+
+	var content []string
+	for i := 0; i < MaxPerPost+5; i++ {
+		content = append(content, "#tag"+strconv.Itoa(i))
+	}
+	if got := Merge([]string{"go"}, strings.Join(content, " ")); len(got) != MaxPerPost || got[0] != "go" {
+		t.Errorf("Merge() = %v, want the explicit tag and hashtags up to %d tags", got, MaxPerPost)
+	}
+}
+
+func TestExplicit(t *testing.T) {
+	got := Explicit([]string{"go", "web", "api"}, "#web rocks #api", []string{"api"})
+	if want := []string{"go", "api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Explicit() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+// Package webhooks signs and sends outbound webhook requests.
+//
+// Every request carries the delivery ID, the event type, a Unix timestamp and
+// an HMAC-SHA256 signature of "<timestamp>.<body>" keyed with the webhook
+// secret. Receivers should recompute the signature, compare it in constant
+// time and reject timestamps too far in the past to prevent replays.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Social-Signature"
+	TimestampHeader = "X-Social-Timestamp"
+	EventHeader     = "X-Social-Event"
+	DeliveryHeader  = "X-Social-Delivery"
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature doesn't match")
+	ErrExpiredTimestamp = errors.New("webhook timestamp is outside the tolerance")
+	ErrForbiddenAddress = errors.New("webhook address isn't a public one")
+)
+
+// PublicAddress reports whether deliveries may be sent to addr: loopback,
+// private, link-local, multicast and unspecified addresses are internal to
+// the network the API runs in.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// CheckHost resolves host and fails with ErrForbiddenAddress when any of its
+// addresses isn't public. It catches bad URLs early; the Sender checks the
+// address it connects to again, as DNS answers can change in between.
+func CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolving webhook host: %w", err)
+	}
+
+	for _, addr := range addrs {
+		if !PublicAddress(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// NewSecret returns a random hex encoded secret to sign deliveries with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received request
+// against body, accepting timestamps up to tolerance away from now.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrExpiredTimestamp
+	}
+
+	if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(SignatureHeader))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Backoff returns how long to wait before retrying a delivery that failed
+// attempts times: 30s doubling on every attempt, capped at six hours.
+func Backoff(attempts int) time.Duration {
+	const (
+		base = 30 * time.Second
+		max  = 6 * time.Hour
+	)
+
+	if attempts < 1 {
+		return base
+	}
+
+	d := base
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return d
+}
+
+// Request is a single delivery attempt.
+type Request struct {
+	DeliveryID int64
+	URL        string
+	Secret     string
+	EventType  string
+	Body       []byte
+}
+
+// Sender posts signed deliveries to webhook URLs, only ever connecting to
+// public addresses.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return newSender(timeout, PublicAddress)
+}
+
+// newSender returns a Sender connecting only to the addresses allow accepts.
+// The check runs on the address actually dialed, after DNS resolution, so a
+// host resolving to a public address when registered and to an internal one
+// later is still refused.
+func newSender(timeout time.Duration, allow func(netip.Addr) bool) *Sender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allow(addrPort.Addr()) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			// no proxy: it would be the one dialed, and checked, instead
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// a redirect could point the signed payload somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send delivers req and returns the response status, 0 when no response was
+// received. Any status outside 2xx is reported as an error.
+func (s *Sender) Send(ctx context.Context, req Request) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := time.Now().Unix()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "GopherSocial-Webhooks/1.0")
+	httpReq.Header.Set(DeliveryHeader, strconv.FormatInt(req.DeliveryID, 10))
+	httpReq.Header.Set(EventHeader, req.EventType)
+	httpReq.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, ts, req.Body))
+
+	res, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// drain a bounded amount so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded with %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
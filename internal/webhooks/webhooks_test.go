@@ -0,0 +1,195 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "secret"
+	body := []byte(`{"id":1}`)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid", strconv.FormatInt(now, 10), Sign(secret, now, body), body, nil},
+		{"wrong secret", strconv.FormatInt(now, 10), Sign("other", now, body), body, ErrInvalidSignature},
+		{"tampered body", strconv.FormatInt(now, 10), Sign(secret, now, body), []byte(`{"id":2}`), ErrInvalidSignature},
+		{"signature of another timestamp", strconv.FormatInt(now, 10), Sign(secret, now-1, body), body, ErrInvalidSignature},
+		{"missing signature", strconv.FormatInt(now, 10), "", body, ErrInvalidSignature},
+		{"expired", strconv.FormatInt(now-600, 10), Sign(secret, now-600, body), body, ErrExpiredTimestamp},
+		{"in the future", strconv.FormatInt(now+600, 10), Sign(secret, now+600, body), body, ErrExpiredTimestamp},
+		{"missing timestamp", "", Sign(secret, now, body), body, ErrExpiredTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(TimestampHeader, tt.timestamp)
+			header.Set(SignatureHeader, tt.signature)
+
+			if err := Verify(secret, header, tt.body, 5*time.Minute); err != tt.want {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := PublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "::1", "localhost", "169.254.169.254"} {
+		if err := CheckHost(context.Background(), host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%s) = %v, want %v", host, err, ErrForbiddenAddress)
+		}
+	}
+}
+
+// anyAddress lets tests deliver to httptest receivers, which listen on loopback.
+func anyAddress(netip.Addr) bool { return true }
+
+func TestSenderSignsDeliveries(t *testing.T) {
+	const secret = "whsec"
+	body := []byte(`{"id":7,"type":"post.created"}`)
+
+	received := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = Verify(secret, r.Header, got, time.Minute)
+		}
+		if err == nil && r.Header.Get(EventHeader) != "post.created" {
+			err = errors.New("unexpected event header " + r.Header.Get(EventHeader))
+		}
+		if err == nil && r.Header.Get(DeliveryHeader) != "42" {
+			err = errors.New("unexpected delivery header " + r.Header.Get(DeliveryHeader))
+		}
+		received <- err
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sender := newSender(5*time.Second, anyAddress)
+	status, err := sender.Send(context.Background(), Request{
+		DeliveryID: 42,
+		URL:        srv.URL,
+		Secret:     secret,
+		EventType:  "post.created",
+		Body:       body,
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("Send() status = %d, want %d", status, http.StatusNoContent)
+	}
+	if err := <-received; err != nil {
+		t.Errorf("receiver rejected the delivery: %v", err)
+	}
+}
+
+func TestSenderReportsReceiverFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusInternalServerError},
+		{"redirect isn't followed", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://example.com/elsewhere", http.StatusFound)
+		}, http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			sender := newSender(5*time.Second, anyAddress)
+			status, err := sender.Send(context.Background(), Request{URL: srv.URL, Secret: "s", Body: []byte(`{}`)})
+			if err == nil {
+				t.Fatal("Send() error = nil, want an error")
+			}
+			if status != tt.status {
+				t.Errorf("Send() status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestSenderRefusesInternalAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	status, err := NewSender(5*time.Second).Send(context.Background(), Request{URL: srv.URL, Secret: "s", Body: []byte(`{}`)})
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Send() error = %v, want %v", err, ErrForbiddenAddress)
+	}
+	if status != 0 || called {
+		t.Errorf("the receiver on loopback was reached, status %d", status)
+	}
+}